@@ -12,11 +12,24 @@ var CLI struct {
 
 	File       kusto.FileIngestOptions `cmd:"" help:"Ingest data from local file."`
 	Management kusto.ManagementOptions `cmd:"" aliases:"mgmt" help:"Run Kusto management commands from a file."`
+	Query      kusto.QueryOptions      `cmd:"" help:"Run a KQL query."`
+	Mapping    kusto.MappingOptions    `cmd:"" help:"Manage ingestion mappings."`
+	Schema     kusto.SchemaOptions     `cmd:"" help:"Manage table schemas."`
+	Migrate    kusto.MigrateOptions    `cmd:"" help:"Apply versioned schema migrations."`
+	Apply      kusto.ApplyOptions      `cmd:"" help:"Apply a desired-state file of tables, mappings and policies."`
 }
 
 // Main is the entry point for the CLI application.
 func Main() {
-	ctx := kong.Parse(&CLI)
+	ctx := kong.Parse(
+		&CLI,
+		kong.Vars{
+			"data_formats":          kusto.DataFormatsEnum,
+			"compressions":          kusto.CompressionsEnum,
+			"result_outputs":        kusto.ResultOutputsEnum,
+			"query_parameter_types": kusto.QueryParameterTypesEnum,
+		},
+	)
 
 	ctx.BindTo(cli.Default(CLI.Verbose), (*cli.Provider)(nil))
 
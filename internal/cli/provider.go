@@ -2,6 +2,7 @@ package cli
 
 import (
 	"context"
+	"io"
 	"os"
 	"os/signal"
 
@@ -15,6 +16,19 @@ type Provider interface {
 
 	// Logger - creates a logger for the command to use.
 	Logger() *log.Logger
+
+	// Stdin - returns the reader for the command's standard input.
+	Stdin() io.Reader
+
+	// Stdout - returns the writer for the command's standard output.
+	Stdout() io.Writer
+
+	// Stderr - returns the writer for the command's standard error, e.g. for prompts.
+	Stderr() io.Writer
+
+	// IsInteractive - reports whether the command is attached to a terminal,
+	// so the user can be prompted.
+	IsInteractive() bool
 }
 
 type providerImpl struct {
@@ -45,4 +59,29 @@ func (p *providerImpl) Context() (context.Context, context.CancelFunc) {
 
 func (p *providerImpl) Logger() *log.Logger {
 	return p.logger
-}
\ No newline at end of file
+}
+
+func (p *providerImpl) Stdin() io.Reader {
+	return os.Stdin
+}
+
+func (p *providerImpl) Stdout() io.Writer {
+	return os.Stdout
+}
+
+func (p *providerImpl) Stderr() io.Writer {
+	return os.Stderr
+}
+
+func (p *providerImpl) IsInteractive() bool {
+	return isTerminal(os.Stdin) && isTerminal(os.Stderr)
+}
+
+func isTerminal(f *os.File) bool {
+	stat, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return stat.Mode()&os.ModeCharDevice != 0
+}
@@ -3,6 +3,7 @@ package testingcli
 import (
 	"context"
 	"io"
+	"strings"
 
 	"github.com/Azure/kusto-ingest/internal/cli"
 	"github.com/charmbracelet/log"
@@ -13,6 +14,14 @@ type TestProvider struct {
 	ContextFn func() (context.Context, context.CancelFunc)
 
 	LoggerFn func() *log.Logger
+
+	StdinFn func() io.Reader
+
+	StdoutFn func() io.Writer
+
+	StderrFn func() io.Writer
+
+	IsInteractiveFn func() bool
 }
 
 var _ cli.Provider = (*TestProvider)(nil)
@@ -26,6 +35,18 @@ func New(ms ...func(*TestProvider)) *TestProvider {
 		LoggerFn: func() *log.Logger {
 			return log.New(io.Discard)
 		},
+		StdinFn: func() io.Reader {
+			return strings.NewReader("")
+		},
+		StdoutFn: func() io.Writer {
+			return io.Discard
+		},
+		StderrFn: func() io.Writer {
+			return io.Discard
+		},
+		IsInteractiveFn: func() bool {
+			return false
+		},
 	}
 
 	for _, m := range ms {
@@ -41,4 +62,20 @@ func (tp *TestProvider) Context() (context.Context, context.CancelFunc) {
 
 func (tp *TestProvider) Logger() *log.Logger {
 	return tp.LoggerFn()
+}
+
+func (tp *TestProvider) Stdin() io.Reader {
+	return tp.StdinFn()
+}
+
+func (tp *TestProvider) Stdout() io.Writer {
+	return tp.StdoutFn()
+}
+
+func (tp *TestProvider) Stderr() io.Writer {
+	return tp.StderrFn()
+}
+
+func (tp *TestProvider) IsInteractive() bool {
+	return tp.IsInteractiveFn()
 }
\ No newline at end of file
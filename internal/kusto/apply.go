@@ -0,0 +1,176 @@
+package kusto
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/ingest"
+	"github.com/Azure/azure-kusto-go/kusto/kql"
+	"github.com/Azure/kusto-ingest/internal/cli"
+)
+
+func (a ApplyOptions) Validate() error {
+	return a.KustoTarget.validate(false)
+}
+
+// planState reads the current state of the desired tables and plans the changes.
+func (a ApplyOptions) planState(
+	ctx context.Context,
+	cli cli.Provider,
+	queryClient ingest.QueryClient,
+	state desiredState,
+) (statePlan, error) {
+	var plan statePlan
+
+	var tables map[string]bool
+	invokeShowTables := func() error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		var err error
+		tables, err = showTableNames(ctx, queryClient, a.KustoTarget.Database)
+		return err
+	}
+	if err := invokeWithRetries(invokeShowTables, a.MaxRetries, a.MaxTimeout, cli.Logger()); err != nil {
+		return plan, fmt.Errorf("show tables: %w", err)
+	}
+
+	for _, desired := range state.Tables {
+		var current *currentTable
+		if tables[desired.Name] {
+			var t currentTable
+			invokeShowTable := func() error {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+
+				var err error
+				t, err = showCurrentTable(ctx, queryClient, a.KustoTarget.Database, desired.Name)
+				return err
+			}
+			if err := invokeWithRetries(invokeShowTable, a.MaxRetries, a.MaxTimeout, cli.Logger()); err != nil {
+				return plan, fmt.Errorf("table %q: %w", desired.Name, err)
+			}
+			current = &t
+		}
+
+		tablePlan, err := diffTable(desired, current)
+		if err != nil {
+			return plan, fmt.Errorf("table %q: %w", desired.Name, err)
+		}
+		plan.add("", tablePlan.Changes...)
+		plan.Commands = append(plan.Commands, tablePlan.Commands...)
+	}
+
+	return plan, nil
+}
+
+// writeStatePlan writes the planned changes, followed by the commands applying them
+// separated by blank lines, so that they can be run with the management command.
+func writeStatePlan(w io.Writer, plan statePlan) error {
+	if len(plan.Changes) == 0 {
+		_, err := fmt.Fprintln(w, "No changes.")
+		return err
+	}
+
+	for _, c := range plan.Changes {
+		_, _ = fmt.Fprintf(w, "  %s\n", c)
+	}
+	if len(plan.Commands) == 0 {
+		_, err := fmt.Fprintln(w, "\nNo commands to run.")
+		return err
+	}
+
+	_, _ = fmt.Fprintf(w, "\nPlan: %d commands.\n", len(plan.Commands))
+	for _, command := range plan.Commands {
+		if _, err := fmt.Fprintf(w, "\n%s\n", strings.TrimRight(command, "\n")); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (a ApplyOptions) Run(cli cli.Provider) error {
+	cli.Logger().Debug(
+		"apply settings",
+		"stateFile", a.StateFile,
+		"plan", a.Plan,
+		"autoApprove", a.AutoApprove,
+		"allowDestructive", a.AllowDestructive,
+		"target.endpoint", a.KustoTarget.Endpoint,
+		"target.database", a.KustoTarget.Database,
+		"auth.tenant", a.Auth.TenantID,
+		"auth.clientID", a.Auth.ClientID,
+		"maxRetries", a.MaxRetries,
+		"maxTimeout", a.MaxTimeout,
+	)
+
+	state, err := loadDesiredState(a.StateFile)
+	if err != nil {
+		return err
+	}
+
+	queryClient, err := a.createQueryClient(a.KustoTarget, a.Auth)
+	if err != nil {
+		return fmt.Errorf("create Kusto query client: %w", err)
+	}
+	defer func() { _ = queryClient.Close() }()
+
+	ctx, cancel := cli.Context()
+	defer cancel()
+
+	plan, err := a.planState(ctx, cli, queryClient, state)
+	if err != nil {
+		return err
+	}
+	if err := writeStatePlan(cli.Stdout(), plan); err != nil {
+		return err
+	}
+	if a.Plan || len(plan.Commands) == 0 {
+		return nil
+	}
+
+	// destructive changes need --allow-destructive or a confirmation, also with --auto-approve
+	var destructive []string
+	for i, command := range plan.Commands {
+		if classifyStatement(command) == commandDestructive {
+			destructive = append(destructive, fmt.Sprintf("command %d: %s", i+1, firstLine(command)))
+		}
+	}
+	if err := confirmDestructiveCommands(cli, destructive, a.AllowDestructive); err != nil {
+		return err
+	}
+
+	if !a.AutoApprove {
+		if !cli.IsInteractive() {
+			return fmt.Errorf("refusing to apply %d commands without --auto-approve", len(plan.Commands))
+		}
+		confirmed, err := promptConfirm(cli, "Apply the changes?")
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			return fmt.Errorf("changes not confirmed")
+		}
+	}
+
+	start := time.Now()
+	for i, command := range plan.Commands {
+		cli.Logger().Info("applying change", "command", i+1, "of", len(plan.Commands))
+		cli.Logger().Debug("apply command", "command", command)
+
+		stmt := kql.New("").AddUnsafe(command)
+		err := executeMgmt(ctx, cli, queryClient, a.KustoTarget.Database, stmt, a.MaxRetries, a.MaxTimeout)
+		if err != nil {
+			return fmt.Errorf("apply command %d (%s): %w", i+1, firstLine(command), err)
+		}
+	}
+
+	cli.Logger().Info("changes applied successfully", "commands", len(plan.Commands), "duration", time.Since(start))
+	return nil
+}
@@ -0,0 +1,226 @@
+package kusto
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto"
+	"github.com/Azure/azure-kusto-go/kusto/ingest"
+	"github.com/Azure/kusto-ingest/internal/cli/testingcli"
+	"github.com/Azure/kusto-ingest/internal/kusto/testingkusto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ApplyOptions_Run(t *testing.T) {
+	stateFile := writeTestStateFile(t, "state.yaml", `
+tables:
+  - name: Logs
+    columns:
+      - {name: Timestamp, type: datetime}
+      - {name: Message, type: string}
+    policies:
+      retention: {softDeletePeriod: 365d}
+  - name: Metrics
+    columns:
+      - {name: Name, type: string}
+`)
+
+	// Logs exists with an outdated retention policy, Metrics is missing
+	newQueryClient := func(t *testing.T, executed *[]string) *testingkusto.QueryClient {
+		return testingkusto.NewQueryClient(func(qc *testingkusto.QueryClient) {
+			qc.MgmtFn = func(_ context.Context, db string, stmt kusto.Statement, _ ...kusto.QueryOption) (*kusto.RowIterator, error) {
+				assert.Equal(t, "TestDatabase", db)
+				switch stmt.String() {
+				case ".show tables":
+					return newTestStringRows(t, []string{"TableName"}, []string{"Logs"}), nil
+				case ".show table ['Logs'] schema as json":
+					return newTestStringRows(t, []string{"TableName", "Schema"}, []string{
+						"Logs",
+						`{"Name":"Logs","OrderedColumns":[{"Name":"Timestamp","CslType":"datetime"},{"Name":"Message","CslType":"string"}]}`,
+					}), nil
+				case ".show table ['Logs'] ingestion mappings":
+					return newTestMappingRows(t), nil
+				case ".show table ['Logs'] policy retention":
+					return newTestStringRows(t, []string{"PolicyName", "Policy"}, []string{
+						"RetentionPolicy", `{"SoftDeletePeriod":"30.00:00:00","Recoverability":"Enabled"}`,
+					}), nil
+				case ".show table ['Logs'] policy caching", ".show table ['Logs'] policy update":
+					return newTestStringRows(t, []string{"PolicyName", "Policy"}, []string{"Policy", "null"}), nil
+				}
+				*executed = append(*executed, stmt.String())
+				return nil, nil
+			}
+		})
+	}
+
+	run := func(t *testing.T, opts ApplyOptions, cli *testingcli.TestProvider) (string, []string, error) {
+		var executed []string
+		q := newQueryClient(t, &executed)
+
+		var stdout bytes.Buffer
+		cli.StdoutFn = func() io.Writer { return &stdout }
+
+		opts.StateFile = stateFile
+		opts.Auth = newTestAuth()
+		opts.KustoTarget = KustoTargetOptions{Endpoint: "https://example.kusto.windows.net", Database: "TestDatabase"}
+		opts.ingestorBuildSettings = ingestorBuildSettings{
+			CreateQueryClient: func(target KustoTargetOptions, auth AuthOptions) (ingest.QueryClient, error) {
+				return q, nil
+			},
+		}
+		require.NoError(t, opts.Validate())
+
+		err := opts.Run(cli)
+		return stdout.String(), executed, err
+	}
+
+	expectedCommands := []string{
+		".alter-merge table ['Logs'] policy retention softdelete = 365d",
+		".create-merge table Metrics (\n    Name: string\n)\n",
+	}
+
+	t.Run("plan", func(t *testing.T) {
+		stdout, executed, err := run(t, ApplyOptions{Plan: true}, testingcli.New())
+		require.NoError(t, err)
+		assert.Empty(t, executed)
+		assert.Equal(t, `  ~ retention policy Logs: softDeletePeriod 30.00:00:00 -> 365d
+  + table Metrics
+  + column Metrics.Name: string
+
+Plan: 2 commands.
+
+.alter-merge table ['Logs'] policy retention softdelete = 365d
+
+.create-merge table Metrics (
+    Name: string
+)
+`, stdout)
+	})
+
+	t.Run("auto approve", func(t *testing.T) {
+		_, executed, err := run(t, ApplyOptions{AutoApprove: true, AllowDestructive: true}, testingcli.New())
+		require.NoError(t, err)
+		assert.Equal(t, expectedCommands, executed)
+	})
+
+	t.Run("retention change not allowed", func(t *testing.T) {
+		// a shorter soft delete period deletes data, so retention changes are destructive
+		_, executed, err := run(t, ApplyOptions{AutoApprove: true}, testingcli.New())
+		assert.ErrorContains(t, err, "refusing to run 1 destructive commands without --allow-destructive:\ncommand 1: .alter-merge table ['Logs'] policy retention softdelete = 365d")
+		assert.Empty(t, executed)
+	})
+
+	t.Run("not interactive", func(t *testing.T) {
+		_, executed, err := run(t, ApplyOptions{AllowDestructive: true}, testingcli.New())
+		assert.ErrorContains(t, err, "refusing to apply 2 commands without --auto-approve")
+		assert.Empty(t, executed)
+	})
+
+	newInteractiveCLI := func(answer string, stderr io.Writer) *testingcli.TestProvider {
+		return testingcli.New(func(tp *testingcli.TestProvider) {
+			tp.IsInteractiveFn = func() bool { return true }
+			tp.StdinFn = func() io.Reader { return strings.NewReader(answer) }
+			tp.StderrFn = func() io.Writer { return stderr }
+		})
+	}
+
+	t.Run("confirmed", func(t *testing.T) {
+		var stderr bytes.Buffer
+		_, executed, err := run(t, ApplyOptions{AllowDestructive: true}, newInteractiveCLI("y\n", &stderr))
+		require.NoError(t, err)
+		assert.Equal(t, "Apply the changes? [y/N] ", stderr.String())
+		assert.Equal(t, expectedCommands, executed)
+	})
+
+	t.Run("not confirmed", func(t *testing.T) {
+		_, executed, err := run(t, ApplyOptions{AllowDestructive: true}, newInteractiveCLI("\n", io.Discard))
+		assert.ErrorContains(t, err, "changes not confirmed")
+		assert.Empty(t, executed)
+	})
+}
+
+func Test_ApplyOptions_Run_Destructive(t *testing.T) {
+	stateFile := writeTestStateFile(t, "state.yaml", `
+tables:
+  - name: Logs
+    columns:
+      - {name: Timestamp, type: date}
+      - {name: Message, type: string}
+`)
+
+	// Logs exists with Timestamp as datetime, the date alias is up to date, and Message as int
+	run := func(t *testing.T, opts ApplyOptions, cli *testingcli.TestProvider) ([]string, error) {
+		var executed []string
+		q := testingkusto.NewQueryClient(func(qc *testingkusto.QueryClient) {
+			qc.MgmtFn = func(_ context.Context, db string, stmt kusto.Statement, _ ...kusto.QueryOption) (*kusto.RowIterator, error) {
+				switch stmt.String() {
+				case ".show tables":
+					return newTestStringRows(t, []string{"TableName"}, []string{"Logs"}), nil
+				case ".show table ['Logs'] schema as json":
+					return newTestStringRows(t, []string{"TableName", "Schema"}, []string{
+						"Logs",
+						`{"Name":"Logs","OrderedColumns":[{"Name":"Timestamp","CslType":"datetime"},{"Name":"Message","CslType":"int"}]}`,
+					}), nil
+				case ".show table ['Logs'] ingestion mappings":
+					return newTestMappingRows(t), nil
+				case ".show table ['Logs'] policy retention", ".show table ['Logs'] policy caching", ".show table ['Logs'] policy update":
+					return newTestStringRows(t, []string{"PolicyName", "Policy"}, []string{"Policy", "null"}), nil
+				}
+				executed = append(executed, stmt.String())
+				return nil, nil
+			}
+		})
+
+		cli.StdoutFn = func() io.Writer { return io.Discard }
+		opts.StateFile = stateFile
+		opts.Auth = newTestAuth()
+		opts.KustoTarget = KustoTargetOptions{Endpoint: "https://example.kusto.windows.net", Database: "TestDatabase"}
+		opts.ingestorBuildSettings = ingestorBuildSettings{
+			CreateQueryClient: func(target KustoTargetOptions, auth AuthOptions) (ingest.QueryClient, error) {
+				return q, nil
+			},
+		}
+
+		err := opts.Run(cli)
+		return executed, err
+	}
+
+	t.Run("refused with auto approve", func(t *testing.T) {
+		executed, err := run(t, ApplyOptions{AutoApprove: true}, testingcli.New())
+		assert.ErrorContains(t, err, "refusing to run 1 destructive commands without --allow-destructive:\ncommand 1: .alter column ['Logs'].['Message'] type=string")
+		assert.Empty(t, executed)
+	})
+
+	t.Run("allowed", func(t *testing.T) {
+		executed, err := run(t, ApplyOptions{AutoApprove: true, AllowDestructive: true}, testingcli.New())
+		require.NoError(t, err)
+		assert.Equal(t, []string{".alter column ['Logs'].['Message'] type=string"}, executed)
+	})
+
+	t.Run("not confirmed", func(t *testing.T) {
+		cli := testingcli.New(func(tp *testingcli.TestProvider) {
+			tp.IsInteractiveFn = func() bool { return true }
+			tp.StdinFn = func() io.Reader { return strings.NewReader("n\n") }
+			tp.StderrFn = func() io.Writer { return io.Discard }
+		})
+		executed, err := run(t, ApplyOptions{AutoApprove: true}, cli)
+		assert.ErrorContains(t, err, "destructive commands not confirmed")
+		assert.Empty(t, executed)
+	})
+}
+
+func Test_writeStatePlan(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, writeStatePlan(&buf, statePlan{}))
+	assert.Equal(t, "No changes.\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, writeStatePlan(&buf, statePlan{
+		Changes: []stateChange{{Action: stateChangeIgnore, Subject: "column Logs.Host", Detail: "not in the desired state, not dropped"}},
+	}))
+	assert.Equal(t, "  ! column Logs.Host: not in the desired state, not dropped\n\nNo commands to run.\n", buf.String())
+}
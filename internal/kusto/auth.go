@@ -19,12 +19,31 @@ func (a AuthOptions) UseManagedIdentity() bool {
 	return a.ManagedIdentityResourceID != ""
 }
 
+func (a AuthOptions) UseWorkloadIdentity() bool {
+	return a.TenantID != "" && a.ClientID != "" && a.FederatedTokenFile != ""
+}
+
+// workloadIdentityCredentialOptions sets the workload identity explicitly, so that it
+// doesn't fall back to the environment variables when the options are unset.
+func (a AuthOptions) workloadIdentityCredentialOptions() *azidentity.WorkloadIdentityCredentialOptions {
+	return &azidentity.WorkloadIdentityCredentialOptions{
+		ClientID:      a.ClientID,
+		TenantID:      a.TenantID,
+		TokenFilePath: a.FederatedTokenFile,
+	}
+}
+
 // PrepareKustoConnectionStringBuilder setups the connection string for the Kusto client.
 // The authentication method is determined by the provided AuthOptions with the following priority:
 //
 // - azcli
 // - managed identity
 // - client id/secret
+// - workload identity (federated token file)
+//
+// The client secret is given explicitly, while the federated token file may be set
+// in the environment (e.g. by the Azure Workload Identity webhook), so the secret
+// takes priority.
 func (a AuthOptions) PrepareKustoConnectionStringBuilder(b *kusto.ConnectionStringBuilder) error {
 	switch {
 	case a.UseAZCLI():
@@ -41,13 +60,21 @@ func (a AuthOptions) PrepareKustoConnectionStringBuilder(b *kusto.ConnectionStri
 		b.WithTokenCredential(cred)
 	case a.UseClientSecret():
 		b.WithAadAppKey(a.ClientID, a.ClientSecret, a.TenantID)
+	case a.UseWorkloadIdentity():
+		// the token file is read when a token is requested, so that a rotated
+		// token (e.g. a Kubernetes projected service account token) is picked up.
+		cred, err := azidentity.NewWorkloadIdentityCredential(a.workloadIdentityCredentialOptions())
+		if err != nil {
+			return fmt.Errorf("creating workload identity credential: %w", err)
+		}
+		b.WithTokenCredential(cred)
 	}
 
 	return nil
 }
 
 func (a AuthOptions) Validate() error {
-	if a.UseClientSecret() || a.UseManagedIdentity() || a.UseAZCLI() {
+	if a.UseClientSecret() || a.UseManagedIdentity() || a.UseWorkloadIdentity() || a.UseAZCLI() {
 		return nil
 	}
 
@@ -1,9 +1,19 @@
 package kusto
 
 import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/Azure/azure-kusto-go/kusto"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestAuthOptions_Validate(t *testing.T) {
@@ -44,6 +54,23 @@ func TestAuthOptions_Validate(t *testing.T) {
 			},
 			expectedErr: true,
 		},
+		{
+			name: "workload identity",
+			opts: AuthOptions{
+				TenantID:           "tenant-id",
+				ClientID:           "client-id",
+				FederatedTokenFile: "/var/run/secrets/azure/tokens/azure-identity-token",
+			},
+			expectedErr: false,
+		},
+		{
+			name: "partial workload identity",
+			opts: AuthOptions{
+				ClientID:           "client-id",
+				FederatedTokenFile: "/var/run/secrets/azure/tokens/azure-identity-token",
+			},
+			expectedErr: true,
+		},
 		{
 			name: "azcli and client id/secret",
 			opts: AuthOptions{
@@ -67,3 +94,86 @@ func TestAuthOptions_Validate(t *testing.T) {
 		})
 	}
 }
+
+// fakeTokenTransport fakes the Microsoft Entra tenant metadata and token endpoints.
+type fakeTokenTransport struct {
+	t         testing.TB
+	assertion string
+}
+
+func (f fakeTokenTransport) Do(req *http.Request) (*http.Response, error) {
+	body := `{
+		"authorization_endpoint": "https://login.microsoftonline.com/tenant-id/oauth2/v2.0/authorize",
+		"token_endpoint": "https://login.microsoftonline.com/tenant-id/oauth2/v2.0/token",
+		"issuer": "https://login.microsoftonline.com/tenant-id/v2.0"
+	}`
+	if strings.HasSuffix(req.URL.Path, "/token") {
+		require.NoError(f.t, req.ParseForm())
+		assert.Equal(f.t, "client-id", req.PostForm.Get("client_id"))
+		assert.Equal(f.t, f.assertion, req.PostForm.Get("client_assertion"))
+		body = `{"access_token": "access-token", "expires_in": 3600, "token_type": "Bearer"}`
+	}
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+func TestAuthOptions_WorkloadIdentity(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "azure-identity-token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("federated-token"), 0o600))
+
+	opts := AuthOptions{
+		TenantID:           "tenant-id",
+		ClientID:           "client-id",
+		FederatedTokenFile: tokenFile,
+	}
+	require.NoError(t, opts.Validate())
+
+	t.Run("connection string", func(t *testing.T) {
+		b := kusto.NewConnectionStringBuilder("https://example.kusto.windows.net")
+		require.NoError(t, opts.PrepareKustoConnectionStringBuilder(b))
+		assert.IsType(t, &azidentity.WorkloadIdentityCredential{}, b.TokenCredential)
+	})
+
+	t.Run("client secret priority", func(t *testing.T) {
+		withSecret := opts
+		withSecret.ClientSecret = "client-secret"
+
+		b := kusto.NewConnectionStringBuilder("https://example.kusto.windows.net")
+		require.NoError(t, withSecret.PrepareKustoConnectionStringBuilder(b))
+		assert.Nil(t, b.TokenCredential, "explicit client secret takes priority over workload identity")
+		assert.Equal(t, "client-secret", b.ApplicationKey)
+	})
+
+	t.Run("token", func(t *testing.T) {
+		credOpts := opts.workloadIdentityCredentialOptions()
+		credOpts.Transport = fakeTokenTransport{t: t, assertion: "federated-token"}
+		credOpts.DisableInstanceDiscovery = true
+
+		cred, err := azidentity.NewWorkloadIdentityCredential(credOpts)
+		require.NoError(t, err)
+		token, err := cred.GetToken(context.Background(), policy.TokenRequestOptions{
+			Scopes: []string{"https://example.kusto.windows.net/.default"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "access-token", token.Token)
+	})
+
+	t.Run("missing token file", func(t *testing.T) {
+		credOpts := opts.workloadIdentityCredentialOptions()
+		credOpts.TokenFilePath = filepath.Join(t.TempDir(), "missing")
+		credOpts.Transport = fakeTokenTransport{t: t}
+		credOpts.DisableInstanceDiscovery = true
+
+		cred, err := azidentity.NewWorkloadIdentityCredential(credOpts)
+		require.NoError(t, err)
+		_, err = cred.GetToken(context.Background(), policy.TokenRequestOptions{
+			Scopes: []string{"https://example.kusto.windows.net/.default"},
+		})
+		assert.Error(t, err)
+	})
+}
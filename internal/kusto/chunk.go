@@ -0,0 +1,229 @@
+package kusto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// recordReader reads the source data one record at a time.
+type recordReader interface {
+	// Next returns the next record, including its trailing line break.
+	// Returns io.EOF when there are no more records.
+	Next() ([]byte, error)
+}
+
+func newRecordReader(r io.Reader, records recordSplit) (recordReader, error) {
+	switch records {
+	case recordsLines:
+		return &lineRecordReader{r: bufio.NewReader(r)}, nil
+	case recordsQuotedLines:
+		return &lineRecordReader{r: bufio.NewReader(r), quoted: true}, nil
+	case recordsJSON:
+		return newJSONRecordReader(r)
+	default:
+		return nil, fmt.Errorf("data can't be split into records")
+	}
+}
+
+// lineRecordReader reads line based records. When quoted is set, line breaks
+// inside double-quoted values don't end the record.
+type lineRecordReader struct {
+	r      *bufio.Reader
+	quoted bool
+}
+
+func (l *lineRecordReader) Next() ([]byte, error) {
+	var record []byte
+	quotes := 0
+	for {
+		line, err := l.r.ReadBytes('\n')
+		record = append(record, line...)
+		if err != nil {
+			if errors.Is(err, io.EOF) && len(record) > 0 {
+				return append(record, '\n'), nil
+			}
+			return nil, err
+		}
+
+		if !l.quoted {
+			return record, nil
+		}
+
+		// quotes are escaped by doubling them, so an odd count means
+		// the line break is inside a quoted value.
+		quotes += bytes.Count(line, []byte{'"'})
+		if quotes%2 == 0 {
+			return record, nil
+		}
+	}
+}
+
+// jsonRecordReader reads JSON values, either as elements of a top-level array
+// or as concatenated values. Each record is written as a single line.
+type jsonRecordReader struct {
+	dec     *json.Decoder
+	inArray bool
+}
+
+func newJSONRecordReader(r io.Reader) (*jsonRecordReader, error) {
+	br := bufio.NewReader(r)
+
+	// peek the first non-space byte to check for a top-level array
+	inArray := false
+	for {
+		b, err := br.Peek(1)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if bytes.IndexByte([]byte(" \t\r\n"), b[0]) < 0 {
+			inArray = b[0] == '['
+			break
+		}
+		_, _ = br.Discard(1)
+	}
+
+	dec := json.NewDecoder(br)
+	if inArray {
+		// consume the opening bracket, so the elements are decoded one by one
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+	}
+
+	return &jsonRecordReader{dec: dec, inArray: inArray}, nil
+}
+
+func (j *jsonRecordReader) Next() ([]byte, error) {
+	if j.inArray && !j.dec.More() {
+		return nil, io.EOF
+	}
+
+	var raw json.RawMessage
+	if err := j.dec.Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+
+	return buf.Bytes(), nil
+}
+
+// chunkWriter writes records into chunk files of bounded size.
+type chunkWriter struct {
+	dir      string
+	maxBytes int64
+	header   []byte
+
+	chunks  []string
+	current *os.File
+	size    int64
+	records int
+}
+
+func (w *chunkWriter) write(record []byte) error {
+	if w.current != nil && w.records > 0 && w.size+int64(len(record)) > w.maxBytes {
+		if err := w.closeCurrent(); err != nil {
+			return err
+		}
+	}
+
+	if w.current == nil {
+		if err := w.openNext(); err != nil {
+			return err
+		}
+	}
+
+	n, err := w.current.Write(record)
+	w.size += int64(n)
+	w.records++
+	return err
+}
+
+func (w *chunkWriter) openNext() error {
+	p := filepath.Join(w.dir, fmt.Sprintf("chunk-%05d", len(w.chunks)+1))
+	f, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+
+	w.current = f
+	w.chunks = append(w.chunks, p)
+	w.records = 0
+	w.size = 0
+
+	if len(w.header) > 0 {
+		n, err := f.Write(w.header)
+		w.size += int64(n)
+		return err
+	}
+
+	return nil
+}
+
+func (w *chunkWriter) closeCurrent() error {
+	if w.current == nil {
+		return nil
+	}
+
+	err := w.current.Close()
+	w.current = nil
+	return err
+}
+
+// splitIntoChunks splits the records from r into chunk files in dir, each at most
+// maxBytes in size unless a single record is larger. When withHeader is set, the
+// first record is treated as a header and repeated at the beginning of every chunk.
+// Returns the paths of the chunk files in order.
+func splitIntoChunks(
+	r io.Reader,
+	records recordSplit,
+	maxBytes int64,
+	withHeader bool,
+	dir string,
+) ([]string, error) {
+	rr, err := newRecordReader(r, records)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &chunkWriter{dir: dir, maxBytes: maxBytes}
+	defer func() { _ = w.closeCurrent() }()
+
+	for {
+		record, err := rr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read record: %w", err)
+		}
+
+		if withHeader && w.header == nil {
+			w.header = record
+			continue
+		}
+
+		if err := w.write(record); err != nil {
+			return nil, fmt.Errorf("write chunk: %w", err)
+		}
+	}
+
+	if err := w.closeCurrent(); err != nil {
+		return nil, fmt.Errorf("write chunk: %w", err)
+	}
+
+	return w.chunks, nil
+}
@@ -0,0 +1,167 @@
+package kusto
+
+import (
+	"context"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto/ingest"
+	"github.com/Azure/kusto-ingest/internal/cli/testingcli"
+	"github.com/Azure/kusto-ingest/internal/kusto/testingkusto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readChunks(t testing.TB, chunks []string) []string {
+	t.Helper()
+
+	var rv []string
+	for _, c := range chunks {
+		rv = append(rv, string(readTestdata(t, c)))
+	}
+
+	return rv
+}
+
+func Test_splitIntoChunks(t *testing.T) {
+	t.Run("lines", func(t *testing.T) {
+		content := "line 1\nline 2\nline 3\nline 4"
+
+		chunks, err := splitIntoChunks(strings.NewReader(content), recordsLines, 14, false, t.TempDir())
+		require.NoError(t, err)
+		assert.Equal(t, []string{"line 1\nline 2\n", "line 3\nline 4\n"}, readChunks(t, chunks))
+	})
+
+	t.Run("record larger than max bytes", func(t *testing.T) {
+		content := "a very long line\nb\n"
+
+		chunks, err := splitIntoChunks(strings.NewReader(content), recordsLines, 4, false, t.TempDir())
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a very long line\n", "b\n"}, readChunks(t, chunks))
+	})
+
+	t.Run("quoted lines with header", func(t *testing.T) {
+		content := "ts,msg\n" +
+			"2023-07-25,\"hello,\nworld\"\n" +
+			"2023-07-26,\"say \"\"hi\"\"\"\n" +
+			"2023-07-27,bye\n"
+
+		chunks, err := splitIntoChunks(strings.NewReader(content), recordsQuotedLines, 30, true, t.TempDir())
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"ts,msg\n2023-07-25,\"hello,\nworld\"\n",
+			"ts,msg\n2023-07-26,\"say \"\"hi\"\"\"\n",
+			"ts,msg\n2023-07-27,bye\n",
+		}, readChunks(t, chunks))
+	})
+
+	t.Run("json array", func(t *testing.T) {
+		content := `[ {"a": 1}, {"a": [2, 3]},
+			{"a": {"b": 4}} ]`
+
+		chunks, err := splitIntoChunks(strings.NewReader(content), recordsJSON, 20, false, t.TempDir())
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"{\"a\":1}\n{\"a\":[2,3]}\n",
+			"{\"a\":{\"b\":4}}\n",
+		}, readChunks(t, chunks))
+	})
+
+	t.Run("json multi-line objects", func(t *testing.T) {
+		content := "{\n  \"a\": 1\n}\n{\n  \"a\": 2\n}\n"
+
+		chunks, err := splitIntoChunks(strings.NewReader(content), recordsJSON, 8, false, t.TempDir())
+		require.NoError(t, err)
+		assert.Equal(t, []string{"{\"a\":1}\n", "{\"a\":2}\n"}, readChunks(t, chunks))
+	})
+
+	t.Run("invalid json", func(t *testing.T) {
+		_, err := splitIntoChunks(strings.NewReader(`{"a": `), recordsJSON, 8, false, t.TempDir())
+		assert.Error(t, err)
+	})
+
+	t.Run("unsplittable", func(t *testing.T) {
+		_, err := splitIntoChunks(strings.NewReader("PAR1"), recordsUnsplittable, 8, false, t.TempDir())
+		assert.Error(t, err)
+	})
+}
+
+func Test_FileIngestOptions_Run_Chunks(t *testing.T) {
+	for _, sourceFile := range []string{testdataMultiJSON, compressedTestdata["zstd"]} {
+		t.Run(sourceFile, func(t *testing.T) {
+			var (
+				mu       sync.Mutex
+				received []string
+			)
+			ingestor := testingkusto.New(func(ing *testingkusto.Ingestor) {
+				ing.FromFileFunc = func(ctx context.Context, fPath string, options ...ingest.FileOption) (*ingest.Result, error) {
+					mu.Lock()
+					defer mu.Unlock()
+
+					b, err := os.ReadFile(fPath)
+					require.NoError(t, err)
+					assert.LessOrEqual(t, len(b), 200)
+					received = append(received, string(b))
+
+					return &ingest.Result{}, nil
+				}
+			})
+
+			opts := FileIngestOptions{
+				SourceFiles:   []string{sourceFile},
+				Format:        "multijson",
+				MaxChunkBytes: 200,
+				Auth:          newTestAuth(),
+				KustoTarget:   newTestKustoTarget(),
+
+				ingestorBuildSettings: ingestorBuildSettings{
+					CreateIngestor: func(target KustoTargetOptions, auth AuthOptions) (ingest.Ingestor, error) {
+						return ingestor, nil
+					},
+				},
+			}
+
+			err := opts.Run(testingcli.New())
+			require.NoError(t, err)
+
+			expected := strings.ReplaceAll(string(readTestdata(t, testdataMultiJSON)), `": "`, `":"`)
+			expected = strings.ReplaceAll(expected, `", "`, `","`)
+			assert.Greater(t, len(received), 1, "source should be split into multiple chunks")
+			assert.Equal(t, strings.TrimSpace(expected), strings.TrimSpace(strings.Join(received, "")))
+		})
+	}
+
+	t.Run("failed chunk is reported", func(t *testing.T) {
+		calls := 0
+		ingestor := testingkusto.New(func(ing *testingkusto.Ingestor) {
+			ing.FromFileFunc = func(ctx context.Context, fPath string, options ...ingest.FileOption) (*ingest.Result, error) {
+				calls++
+				if calls == 2 {
+					return nil, assert.AnError
+				}
+				return &ingest.Result{}, nil
+			}
+		})
+
+		opts := FileIngestOptions{
+			SourceFiles:   []string{testdataMultiJSON},
+			Format:        "multijson",
+			MaxChunkBytes: 200,
+			Auth:          newTestAuth(),
+			KustoTarget:   newTestKustoTarget(),
+
+			ingestorBuildSettings: ingestorBuildSettings{
+				CreateIngestor: func(target KustoTargetOptions, auth AuthOptions) (ingest.Ingestor, error) {
+					return ingestor, nil
+				},
+			},
+		}
+
+		err := opts.Run(testingcli.New())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "(chunk 2/")
+		assert.Greater(t, calls, 2, "other chunks should still be ingested")
+	})
+}
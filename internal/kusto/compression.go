@@ -0,0 +1,265 @@
+package kusto
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Azure/azure-kusto-go/kusto/ingest"
+	"github.com/Azure/azure-kusto-go/kusto/ingest/ingestoptions"
+	"github.com/klauspost/compress/zstd"
+)
+
+// CompressionString is the compression of the source data.
+type CompressionString string
+
+const (
+	// CompressionAuto detects the compression from the file extension and content.
+	CompressionAuto CompressionString = "auto"
+	// CompressionNone indicates the source data is not compressed.
+	CompressionNone CompressionString = "none"
+)
+
+// compressionDescriptor describes a compression supported by the CLI.
+type compressionDescriptor struct {
+	// Name is the value accepted by the --compression flag.
+	Name CompressionString
+	// Extensions are the file extensions used for detecting the compression.
+	Extensions []string
+	// Magic is the leading bytes used for detecting the compression.
+	Magic []byte
+	// IngestType is the compression type Kusto accepts natively.
+	// Zero value means the data needs to be recompressed before ingestion.
+	IngestType ingestoptions.CompressionType
+	// NewReader creates a decompressing reader for the compressed data.
+	// Nil for zip, which is an archive rather than a stream.
+	NewReader func(r io.Reader) (io.ReadCloser, error)
+}
+
+// supportedCompressions is the registry of the supported compressions.
+// Kusto accepts gzip and zip natively, other compressions are decompressed
+// locally and recompressed to gzip before ingestion.
+// ref: https://learn.microsoft.com/en-us/azure/data-explorer/ingestion-supported-formats#supported-data-compression-formats
+var supportedCompressions = []compressionDescriptor{
+	{
+		Name:       CompressionNone,
+		IngestType: ingestoptions.CTNone,
+	},
+	{
+		Name:       "gzip",
+		Extensions: []string{".gz"},
+		Magic:      []byte{0x1f, 0x8b},
+		IngestType: ingestoptions.GZIP,
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r)
+		},
+	},
+	{
+		Name:       "zip",
+		Extensions: []string{".zip"},
+		Magic:      []byte("PK\x03\x04"),
+		IngestType: ingestoptions.ZIP,
+	},
+	{
+		Name:       "zstd",
+		Extensions: []string{".zst", ".zstd"},
+		Magic:      []byte{0x28, 0xb5, 0x2f, 0xfd},
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			dec, err := zstd.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			return dec.IOReadCloser(), nil
+		},
+	},
+	{
+		Name:       "bz2",
+		Extensions: []string{".bz2"},
+		Magic:      []byte("BZh"),
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			return io.NopCloser(bzip2.NewReader(r)), nil
+		},
+	},
+}
+
+var compressionDescriptorsByString = func() map[CompressionString]compressionDescriptor {
+	rv := map[CompressionString]compressionDescriptor{}
+	for _, c := range supportedCompressions {
+		rv[c.Name] = c
+	}
+
+	return rv
+}()
+
+// CompressionsEnum is the comma separated list of the supported compressions,
+// for use in kong enum tags via the ${compressions} variable.
+var CompressionsEnum = func() string {
+	ss := []string{string(CompressionAuto)}
+	for _, c := range supportedCompressions {
+		ss = append(ss, string(c.Name))
+	}
+
+	return strings.Join(ss, ",")
+}()
+
+func (c CompressionString) Validate() error {
+	if c == CompressionAuto {
+		return nil
+	}
+	if _, ok := compressionDescriptorsByString[c]; !ok {
+		return fmt.Errorf("unsupported compression: %q, supported: %s", c, strings.ReplaceAll(CompressionsEnum, ",", ", "))
+	}
+
+	return nil
+}
+
+func (c CompressionString) descriptor() compressionDescriptor {
+	if d, ok := compressionDescriptorsByString[c]; ok {
+		return d
+	}
+
+	return compressionDescriptorsByString[CompressionNone]
+}
+
+// NeedsRecompress reports whether Kusto doesn't accept the compression natively.
+func (c CompressionString) NeedsRecompress() bool {
+	return c.descriptor().IngestType == ingestoptions.CTUnknown
+}
+
+// FileOptions returns the ingestion options for the data in this compression.
+func (c CompressionString) FileOptions() []ingest.FileOption {
+	switch {
+	case c == CompressionNone || c == "":
+		return nil
+	case c.NeedsRecompress():
+		return []ingest.FileOption{ingest.CompressionType(ingestoptions.GZIP)}
+	default:
+		return []ingest.FileOption{ingest.CompressionType(c.descriptor().IngestType)}
+	}
+}
+
+func compressionByExtension(ext string) (CompressionString, bool) {
+	for _, c := range supportedCompressions {
+		for _, e := range c.Extensions {
+			if e == ext {
+				return c.Name, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// detectCompression detects the compression of the file at path from the file
+// extension of name, falling back to sniffing the leading bytes of the content.
+func detectCompression(name string, path string) (CompressionString, error) {
+	_, ext := splitFileExtensions(name)
+	if c, ok := compressionByExtension(ext); ok {
+		return c, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("read %q for compression detection: %w", name, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	head := make([]byte, 8)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", fmt.Errorf("read %q for compression detection: %w", name, err)
+	}
+	head = head[:n]
+
+	for _, c := range supportedCompressions {
+		if len(c.Magic) > 0 && bytes.HasPrefix(head, c.Magic) {
+			return c.Name, nil
+		}
+	}
+
+	return CompressionNone, nil
+}
+
+// openDecompressed opens the file at path and returns the decompressed content.
+// For zip archives, the content of the first file is returned.
+func openDecompressed(path string, compression CompressionString) (io.ReadCloser, error) {
+	if compression == "zip" {
+		zr, err := zip.OpenReader(path)
+		if err != nil {
+			return nil, err
+		}
+		if len(zr.File) == 0 {
+			_ = zr.Close()
+			return io.NopCloser(bytes.NewReader(nil)), nil
+		}
+
+		f, err := zr.File[0].Open()
+		if err != nil {
+			_ = zr.Close()
+			return nil, err
+		}
+		return &multiCloser{Reader: f, closers: []io.Closer{f, zr}}, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	newReader := compression.descriptor().NewReader
+	if newReader == nil {
+		return f, nil
+	}
+
+	r, err := newReader(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return &multiCloser{Reader: r, closers: []io.Closer{r, f}}, nil
+}
+
+// openRecompressed opens the file at path, decompresses it and streams it back
+// recompressed with gzip.
+func openRecompressed(path string, compression CompressionString) (io.ReadCloser, error) {
+	r, err := openDecompressed(path, compression)
+	if err != nil {
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		defer func() { _ = r.Close() }()
+
+		gw := gzip.NewWriter(pw)
+		_, err := io.Copy(gw, r)
+		if err == nil {
+			err = gw.Close()
+		}
+		_ = pw.CloseWithError(err)
+	}()
+
+	return pr, nil
+}
+
+// multiCloser is a reader which closes all the underlying closers in order.
+type multiCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (m *multiCloser) Close() error {
+	var rv error
+	for _, c := range m.closers {
+		if err := c.Close(); err != nil && rv == nil {
+			rv = err
+		}
+	}
+
+	return rv
+}
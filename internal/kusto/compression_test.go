@@ -0,0 +1,133 @@
+package kusto
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto/ingest"
+	"github.com/Azure/kusto-ingest/internal/cli/testingcli"
+	"github.com/Azure/kusto-ingest/internal/kusto/testingkusto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testdataMultiJSON = "../../testdata/logs.multijson"
+
+var compressedTestdata = map[CompressionString]string{
+	"gzip": "../../testdata/logs.multijson.gz",
+	"zip":  "../../testdata/logs.multijson.zip",
+	"zstd": "../../testdata/logs.multijson.zst",
+	"bz2":  "../../testdata/logs.multijson.bz2",
+}
+
+func readTestdata(t testing.TB, p string) []byte {
+	t.Helper()
+
+	b, err := os.ReadFile(p)
+	require.NoError(t, err)
+
+	return b
+}
+
+func Test_CompressionString_Validate(t *testing.T) {
+	for _, c := range supportedCompressions {
+		assert.NoError(t, c.Name.Validate(), "%q should be valid compression", c.Name)
+	}
+	assert.NoError(t, CompressionAuto.Validate())
+	assert.Error(t, CompressionString("lz4").Validate())
+}
+
+func Test_detectCompression(t *testing.T) {
+	for expected, p := range compressedTestdata {
+		t.Run(string(expected), func(t *testing.T) {
+			c, err := detectCompression(p, p)
+			require.NoError(t, err)
+			assert.Equal(t, expected, c, "detect by extension")
+
+			// stdin data has no file name to detect from
+			noExt := writeToTestFile(t, "data", readTestdata(t, p))
+			c, err = detectCompression(stdinSourceFile, noExt)
+			require.NoError(t, err)
+			assert.Equal(t, expected, c, "detect by content")
+		})
+	}
+
+	c, err := detectCompression(testdataMultiJSON, testdataMultiJSON)
+	require.NoError(t, err)
+	assert.Equal(t, CompressionNone, c)
+}
+
+func Test_openDecompressed(t *testing.T) {
+	expected := readTestdata(t, testdataMultiJSON)
+
+	for c, p := range compressedTestdata {
+		t.Run(string(c), func(t *testing.T) {
+			r, err := openDecompressed(p, c)
+			require.NoError(t, err)
+			defer func() { _ = r.Close() }()
+
+			b, err := io.ReadAll(r)
+			require.NoError(t, err)
+			assert.Equal(t, string(expected), string(b))
+		})
+	}
+}
+
+func Test_FileIngestOptions_Run_CompressedSource(t *testing.T) {
+	expected := readTestdata(t, testdataMultiJSON)
+
+	newOpts := func(sourceFile string, ingestor ingest.Ingestor) FileIngestOptions {
+		return FileIngestOptions{
+			SourceFiles: []string{sourceFile},
+			Format:      "multijson",
+			Compression: CompressionAuto,
+			Auth:        newTestAuth(),
+			KustoTarget: newTestKustoTarget(),
+
+			ingestorBuildSettings: ingestorBuildSettings{
+				CreateIngestor: func(target KustoTargetOptions, auth AuthOptions) (ingest.Ingestor, error) {
+					return ingestor, nil
+				},
+			},
+		}
+	}
+
+	for _, c := range []CompressionString{"gzip", "zip"} {
+		t.Run(string(c)+" is passed through", func(t *testing.T) {
+			called := false
+			ingestor := testingkusto.New(func(ing *testingkusto.Ingestor) {
+				ing.FromFileFunc = func(ctx context.Context, fPath string, options ...ingest.FileOption) (*ingest.Result, error) {
+					called = true
+					assert.Equal(t, compressedTestdata[c], fPath)
+					return &ingest.Result{}, nil
+				}
+			})
+
+			err := newOpts(compressedTestdata[c], ingestor).Run(testingcli.New())
+			assert.NoError(t, err)
+			assert.True(t, called)
+		})
+	}
+
+	for _, c := range []CompressionString{"zstd", "bz2"} {
+		t.Run(string(c)+" is recompressed to gzip", func(t *testing.T) {
+			var received []byte
+			ingestor := testingkusto.New(func(ing *testingkusto.Ingestor) {
+				ing.FromReaderFunc = func(ctx context.Context, reader io.Reader, options ...ingest.FileOption) (*ingest.Result, error) {
+					gr, err := gzip.NewReader(reader)
+					require.NoError(t, err)
+					received, err = io.ReadAll(gr)
+					require.NoError(t, err)
+					return &ingest.Result{}, nil
+				}
+			})
+
+			err := newOpts(compressedTestdata[c], ingestor).Run(testingcli.New())
+			assert.NoError(t, err)
+			assert.Equal(t, string(expected), string(received))
+		})
+	}
+}
@@ -7,34 +7,102 @@ import (
 	"github.com/Azure/azure-kusto-go/kusto/ingest"
 )
 
-// TODO: discuss with upstream to let ingest.DataFormat implement UnmarshalJSON.
-var supportedIngestDataFormatsByString = func() map[DataFormatString]ingest.DataFormat {
-	ss := []ingest.DataFormat{
-		ingest.MultiJSON,
-		ingest.JSON,
-		ingest.CSV,
+// dataFormatDescriptor describes a data format supported by the CLI.
+type dataFormatDescriptor struct {
+	// Name is the value accepted by the --format flag.
+	Name DataFormatString
+	// Format is the Kusto ingestion data format.
+	Format ingest.DataFormat
+	// MappingKind is the kind of ingestion mapping used with this format.
+	MappingKind ingest.DataFormat
+	// Extensions are the file extensions used for detecting the format with --format=auto.
+	Extensions []string
+	// Records is how the data can be split into records for chunking.
+	Records recordSplit
+	// Delimiter is the field separator of the delimiter separated formats, zero otherwise.
+	Delimiter rune
+}
+
+// recordSplit describes how the source data can be split along record boundaries.
+type recordSplit int
+
+const (
+	// recordsUnsplittable means the data is ingested as a whole (e.g. binary formats).
+	recordsUnsplittable recordSplit = iota
+	// recordsLines means each line is a record.
+	recordsLines
+	// recordsQuotedLines means each line is a record, unless the line break is inside a quoted value.
+	recordsQuotedLines
+	// recordsJSON means the records are JSON values, either concatenated or as elements of a top-level array.
+	recordsJSON
+)
+
+// supportedDataFormats is the registry of the supported data formats. The CLI enum,
+// the validation hint and the mapping kinds are all derived from this list.
+// refs:
+// - https://learn.microsoft.com/en-us/azure/data-explorer/ingestion-supported-formats
+// - https://learn.microsoft.com/en-us/kusto/management/mappings
+var supportedDataFormats = []dataFormatDescriptor{
+	{Name: "multijson", Format: ingest.MultiJSON, MappingKind: ingest.JSON, Extensions: []string{".multijson"}, Records: recordsJSON},
+	{Name: "json", Format: ingest.JSON, MappingKind: ingest.JSON, Extensions: []string{".jsonl", ".ndjson"}, Records: recordsJSON},
+	{Name: "csv", Format: ingest.CSV, MappingKind: ingest.CSV, Extensions: []string{".csv"}, Records: recordsQuotedLines, Delimiter: ','},
+	{Name: "tsv", Format: ingest.TSV, MappingKind: ingest.CSV, Extensions: []string{".tsv"}, Records: recordsQuotedLines, Delimiter: '\t'},
+	{Name: "tsve", Format: ingest.TSVE, MappingKind: ingest.CSV, Extensions: []string{".tsve"}, Records: recordsLines},
+	{Name: "psv", Format: ingest.PSV, MappingKind: ingest.CSV, Extensions: []string{".psv"}, Records: recordsQuotedLines, Delimiter: '|'},
+	{Name: "scsv", Format: ingest.SCSV, MappingKind: ingest.CSV, Extensions: []string{".scsv"}, Records: recordsQuotedLines, Delimiter: ';'},
+	{Name: "sohsv", Format: ingest.SOHSV, MappingKind: ingest.CSV, Extensions: []string{".sohsv"}, Records: recordsQuotedLines, Delimiter: '\x01'},
+	{Name: "txt", Format: ingest.TXT, MappingKind: ingest.CSV, Extensions: []string{".txt"}, Records: recordsLines},
+	{Name: "raw", Format: ingest.Raw, MappingKind: ingest.CSV, Extensions: []string{".raw"}},
+	{Name: "w3clogfile", Format: ingest.W3CLogFile, MappingKind: ingest.W3CLogFile, Extensions: []string{".w3clogfile"}},
+	{Name: "parquet", Format: ingest.Parquet, MappingKind: ingest.Parquet, Extensions: []string{".parquet"}},
+	{Name: "avro", Format: ingest.AVRO, MappingKind: ingest.AVRO, Extensions: []string{".avro"}},
+	{Name: "apacheavro", Format: ingest.ApacheAVRO, MappingKind: ingest.AVRO},
+	{Name: "orc", Format: ingest.ORC, MappingKind: ingest.ORC, Extensions: []string{".orc"}},
+}
+
+var dataFormatDescriptorsByString = func() map[DataFormatString]dataFormatDescriptor {
+	rv := map[DataFormatString]dataFormatDescriptor{}
+	for _, d := range supportedDataFormats {
+		rv[d.Name] = d
 	}
 
+	return rv
+}()
+
+// TODO: discuss with upstream to let ingest.DataFormat implement UnmarshalJSON.
+var supportedIngestDataFormatsByString = func() map[DataFormatString]ingest.DataFormat {
 	rv := map[DataFormatString]ingest.DataFormat{}
-	for _, s := range ss {
-		rv[DataFormatString(s.String())] = s
+	for _, d := range supportedDataFormats {
+		rv[d.Name] = d.Format
 	}
 
 	return rv
 }()
 
-var supportedIngestDataFormatsHint = func() string {
-	var ss []string
-	for n := range supportedIngestDataFormatsByString {
-		ss = append(ss, string(n))
+// DataFormatAuto detects the data format from the file extension and content.
+const DataFormatAuto DataFormatString = "auto"
+
+func supportedDataFormatNames() []string {
+	ss := []string{string(DataFormatAuto)}
+	for _, d := range supportedDataFormats {
+		ss = append(ss, string(d.Name))
 	}
 
-	return strings.Join(ss, ", ")
-}()
+	return ss
+}
+
+var supportedIngestDataFormatsHint = strings.Join(supportedDataFormatNames(), ", ")
+
+// DataFormatsEnum is the comma separated list of the supported data formats,
+// for use in kong enum tags via the ${data_formats} variable.
+var DataFormatsEnum = strings.Join(supportedDataFormatNames(), ",")
 
 type DataFormatString string
 
 func (d DataFormatString) Validate() error {
+	if d == DataFormatAuto {
+		return nil
+	}
 	if _, ok := supportedIngestDataFormatsByString[d]; !ok {
 		return fmt.Errorf("unsupported data format: %q, supported: %s", d, supportedIngestDataFormatsHint)
 	}
@@ -44,4 +112,18 @@ func (d DataFormatString) Validate() error {
 
 func (d DataFormatString) ToIngestDataFormat() ingest.DataFormat {
 	return supportedIngestDataFormatsByString[d]
-}
\ No newline at end of file
+}
+
+// MappingKind returns the ingestion mapping kind for the data format.
+func (d DataFormatString) MappingKind() ingest.DataFormat {
+	return dataFormatDescriptorsByString[d].MappingKind
+}
+
+// delimiter returns the field separator of the delimiter separated formats, zero otherwise.
+func (d DataFormatString) delimiter() rune {
+	return dataFormatDescriptorsByString[d].Delimiter
+}
+
+func (d DataFormatString) records() recordSplit {
+	return dataFormatDescriptorsByString[d].Records
+}
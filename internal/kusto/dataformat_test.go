@@ -1,8 +1,10 @@
 package kusto
 
 import (
+	"strings"
 	"testing"
 
+	"github.com/Azure/azure-kusto-go/kusto/ingest"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -25,4 +27,25 @@ func Test_DataFormatString_ToIngestDataFormat(t *testing.T) {
 	for v := range supportedIngestDataFormatsByString {
 		assert.Equal(t, supportedIngestDataFormatsByString[v], v.ToIngestDataFormat(), "%q should be valid data format", v)
 	}
-}
\ No newline at end of file
+}
+
+func Test_DataFormatString_MappingKind(t *testing.T) {
+	for _, d := range supportedDataFormats {
+		// the ingest SDK rejects ingestions when the mapping kind doesn't match the data format
+		assert.Equal(t, d.Format.MappingKind(), d.Name.MappingKind(), "%q should use the mapping kind of its data format", d.Name)
+	}
+
+	assert.Equal(t, ingest.JSON, DataFormatString("multijson").MappingKind())
+	assert.Equal(t, ingest.CSV, DataFormatString("tsv").MappingKind())
+	assert.Equal(t, ingest.Parquet, DataFormatString("parquet").MappingKind())
+	assert.Equal(t, ingest.AVRO, DataFormatString("apacheavro").MappingKind())
+}
+
+func Test_DataFormatsEnum(t *testing.T) {
+	enum := strings.Split(DataFormatsEnum, ",")
+	assert.Contains(t, enum, string(DataFormatAuto))
+	assert.Len(t, enum, len(supportedIngestDataFormatsByString)+1)
+	for _, v := range enum {
+		assert.NoError(t, DataFormatString(v).Validate(), "%q should be valid data format", v)
+	}
+}
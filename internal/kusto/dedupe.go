@@ -0,0 +1,48 @@
+package kusto
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"slices"
+)
+
+// dedupeTagPrefix is the prefix of the content hash tag values, so they can be
+// told apart from user provided ingest-by: tags.
+const dedupeTagPrefix = "sha256:"
+
+// contentHashTag returns the dedupe tag value for the content of the file at path.
+// A non-empty transform is hashed too, so the same source ingested with different
+// transformations gets a different tag.
+func contentHashTag(path string, transform recordTransform) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	if !transform.IsEmpty() {
+		spec, err := json.Marshal(transform)
+		if err != nil {
+			return "", err
+		}
+		_, _ = h.Write([]byte("\x00transform:"))
+		_, _ = h.Write(spec)
+	}
+
+	return dedupeTagPrefix + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// withDedupeTag returns the properties with the tag added as ingest-by: tag and
+// to ingest-if-not-exists, so that ingesting the same data again is a no-op.
+func (p IngestionPropertiesOptions) withDedupeTag(tag string) IngestionPropertiesOptions {
+	p.IngestByTags = append(slices.Clone(p.IngestByTags), tag)
+	p.IngestIfNotExists = append(slices.Clone(p.IngestIfNotExists), tag)
+	return p
+}
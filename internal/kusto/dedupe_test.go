@@ -0,0 +1,133 @@
+package kusto
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto/ingest"
+	"github.com/Azure/kusto-ingest/internal/cli/testingcli"
+	"github.com/Azure/kusto-ingest/internal/kusto/testingkusto"
+	"github.com/charmbracelet/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sha256Tag(content []byte) string {
+	sum := sha256.Sum256(content)
+	return dedupeTagPrefix + hex.EncodeToString(sum[:])
+}
+
+func Test_contentHashTag(t *testing.T) {
+	content := []byte(`{"msg": "hello"}`)
+	path := writeToTestFile(t, "logs.json", content)
+	tag, err := contentHashTag(path, recordTransform{})
+	require.NoError(t, err)
+	assert.Equal(t, sha256Tag(content), tag)
+
+	// the transform changes the ingested data, so it changes the tag
+	renamed, err := contentHashTag(path, recordTransform{Rename: []fieldRename{{From: "msg", To: "Message"}}})
+	require.NoError(t, err)
+	assert.NotEqual(t, tag, renamed)
+
+	dropped, err := contentHashTag(path, recordTransform{Drop: []string{"msg"}})
+	require.NoError(t, err)
+	assert.NotEqual(t, tag, dropped)
+	assert.NotEqual(t, renamed, dropped)
+
+	again, err := contentHashTag(path, recordTransform{Rename: []fieldRename{{From: "msg", To: "Message"}}})
+	require.NoError(t, err)
+	assert.Equal(t, renamed, again)
+
+	_, err = contentHashTag("some-random-file", recordTransform{})
+	assert.Error(t, err)
+}
+
+func Test_IngestionPropertiesOptions_withDedupeTag(t *testing.T) {
+	properties := IngestionPropertiesOptions{
+		IngestByTags:      []string{"batch-1"},
+		IngestIfNotExists: []string{"batch-1"},
+	}
+
+	deduped := properties.withDedupeTag("sha256:abc")
+	assert.Equal(t, []string{"batch-1", "sha256:abc"}, deduped.IngestByTags)
+	assert.Equal(t, []string{"batch-1", "sha256:abc"}, deduped.IngestIfNotExists)
+	assert.Equal(t, []string{"batch-1"}, properties.IngestByTags, "should not modify the original properties")
+}
+
+func Test_FileIngestOptions_Run_Dedupe(t *testing.T) {
+	content := []byte("{\"n\": 1}\n{\"n\": 2}\n")
+	sourceFile := writeToTestFile(t, "logs.json", content)
+
+	run := func(t *testing.T, maxChunkBytes int64) (string, [][]string) {
+		logs := &bytes.Buffer{}
+		cli := testingcli.New(func(tp *testingcli.TestProvider) {
+			tp.LoggerFn = func() *log.Logger { return log.New(logs) }
+		})
+
+		var optionNames [][]string
+		ingestor := testingkusto.New(func(ing *testingkusto.Ingestor) {
+			ing.FromFileFunc = func(ctx context.Context, fPath string, options ...ingest.FileOption) (*ingest.Result, error) {
+				var names []string
+				for _, o := range options {
+					names = append(names, fmt.Sprint(o))
+				}
+				optionNames = append(optionNames, names)
+				return &ingest.Result{}, nil
+			}
+		})
+
+		opts := FileIngestOptions{
+			SourceFiles:   []string{sourceFile},
+			Format:        "json",
+			MaxChunkBytes: maxChunkBytes,
+			Properties:    IngestionPropertiesOptions{Dedupe: true},
+			Auth:          newTestAuth(),
+			KustoTarget:   newTestKustoTarget(),
+
+			ingestorBuildSettings: ingestorBuildSettings{
+				CreateIngestor: func(target KustoTargetOptions, auth AuthOptions) (ingest.Ingestor, error) {
+					return ingestor, nil
+				},
+			},
+		}
+		require.NoError(t, opts.Run(cli))
+
+		return logs.String(), optionNames
+	}
+
+	t.Run("whole file", func(t *testing.T) {
+		logs, optionNames := run(t, 0)
+		assert.Contains(t, logs, "tag="+sha256Tag(content))
+		require.Len(t, optionNames, 1)
+		assert.Contains(t, optionNames[0], "Tags")
+		assert.Contains(t, optionNames[0], "IfNotExists")
+	})
+
+	t.Run("per chunk", func(t *testing.T) {
+		logs, optionNames := run(t, 10)
+		assert.Contains(t, logs, "tag="+sha256Tag([]byte("{\"n\":1}\n")))
+		assert.Contains(t, logs, "tag="+sha256Tag([]byte("{\"n\":2}\n")))
+		assert.Equal(t, 2, strings.Count(logs, "dedupe tag computed"))
+		require.Len(t, optionNames, 2)
+	})
+}
+
+func Test_FileIngestOptions_Validate_Dedupe(t *testing.T) {
+	opts := FileIngestOptions{
+		Format:      "json",
+		Mode:        IngestionModeStreaming,
+		Properties:  IngestionPropertiesOptions{Dedupe: true},
+		KustoTarget: newTestKustoTarget(),
+	}
+	err := opts.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "--dedupe is not supported in streaming ingestion mode, use --mode=queued")
+
+	opts.Mode = IngestionModeQueued
+	assert.NoError(t, opts.Validate())
+}
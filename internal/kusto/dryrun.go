@@ -0,0 +1,614 @@
+package kusto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/ingest"
+	"github.com/Azure/kusto-ingest/internal/cli"
+)
+
+// dryRunMaxReportedErrors is the maximum number of errors printed per source file,
+// the summary still counts all of them.
+const dryRunMaxReportedErrors = 100
+
+var (
+	timespanPattern = regexp.MustCompile(`^-?(\d+\.)?\d{1,2}:\d{2}(:\d{2}(\.\d{1,7})?)?$`)
+	guidPattern     = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+)
+
+// dryRunColumn is a mapping column evaluated against every record.
+type dryRunColumn struct {
+	Name     string
+	DataType string
+	// Path is the parsed JSONPath, for JSON mappings.
+	Path []jsonPathStep
+	// Ordinal is the field index, for csv mappings.
+	Ordinal int
+	// Source is the path or the ordinal of the value, for reporting missing values.
+	Source string
+	// Skip is set for columns whose value isn't taken from the record as is,
+	// i.e. with a Transform or ConstValue.
+	Skip bool
+}
+
+// loadDryRunMapping reads the mappings file and prepares its columns for evaluation.
+func loadDryRunMapping(mappingsFile string, format DataFormatString) ([]dryRunColumn, error) {
+	content, err := os.ReadFile(mappingsFile)
+	if err != nil {
+		return nil, fmt.Errorf("read mappings file %q: %w", mappingsFile, err)
+	}
+
+	var mapping []ingestionMappingEntry
+	if err := json.Unmarshal(content, &mapping); err != nil {
+		return nil, fmt.Errorf("parse mappings file %q: %w", mappingsFile, err)
+	}
+
+	var rv []dryRunColumn
+	for i, m := range mapping {
+		c := dryRunColumn{
+			Name:     m.Column,
+			DataType: strings.ToLower(m.DataType),
+			Skip:     m.Properties.Transform != "" || m.Properties.ConstValue != "",
+		}
+		if c.DataType == "" {
+			c.DataType = kustoTypeString
+		}
+		if !isKnownKustoType(c.DataType) {
+			return nil, fmt.Errorf("mappings file %q: column %q has unsupported DataType %q", mappingsFile, m.Column, m.DataType)
+		}
+
+		if format.MappingKind() == ingest.CSV {
+			c.Ordinal = i
+			if m.Properties.Ordinal != "" {
+				c.Ordinal, err = strconv.Atoi(string(m.Properties.Ordinal))
+				if err != nil {
+					return nil, fmt.Errorf("mappings file %q: column %q: invalid Ordinal %q", mappingsFile, m.Column, m.Properties.Ordinal)
+				}
+			}
+			c.Source = fmt.Sprintf("field %d", c.Ordinal)
+		} else if !c.Skip {
+			c.Path, err = parseJSONPath(m.Properties.Path)
+			if err != nil {
+				return nil, fmt.Errorf("mappings file %q: column %q: %w", mappingsFile, m.Column, err)
+			}
+			c.Source = m.Properties.Path
+		}
+
+		rv = append(rv, c)
+	}
+
+	return rv, nil
+}
+
+// jsonPathStep is a step of a JSONPath, either an object key or an array index.
+type jsonPathStep struct {
+	Key     string
+	Index   int
+	IsIndex bool
+}
+
+// parseJSONPath parses the subset of JSONPath supported by Kusto mappings:
+// $, .key, ['key'], ["key"] and [index].
+// ref: https://learn.microsoft.com/en-us/kusto/management/json-mapping
+func parseJSONPath(path string) ([]jsonPathStep, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("invalid path %q: must start with $", path)
+	}
+
+	var rv []jsonPathStep
+	rest := path[1:]
+	for rest != "" {
+		switch {
+		case rest[0] == '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			key := rest[1 : end+1]
+			if key == "" || key == "*" {
+				return nil, fmt.Errorf("invalid path %q: unsupported segment", path)
+			}
+			rv = append(rv, jsonPathStep{Key: key})
+			rest = rest[end+1:]
+		case strings.HasPrefix(rest, "['") || strings.HasPrefix(rest, `["`):
+			quote := rest[1]
+			var key strings.Builder
+			i := 2
+			for ; i < len(rest) && rest[i] != quote; i++ {
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+				}
+				key.WriteByte(rest[i])
+			}
+			if i+1 >= len(rest) || rest[i+1] != ']' {
+				return nil, fmt.Errorf("invalid path %q: unterminated bracket", path)
+			}
+			rv = append(rv, jsonPathStep{Key: key.String()})
+			rest = rest[i+2:]
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid path %q: unterminated bracket", path)
+			}
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil {
+				return nil, fmt.Errorf("invalid path %q: unsupported index %q", path, rest[1:end])
+			}
+			rv = append(rv, jsonPathStep{Index: index, IsIndex: true})
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("invalid path %q", path)
+		}
+	}
+
+	return rv, nil
+}
+
+// evalJSONPath returns the value at the path, false when the path doesn't exist.
+// An explicit null is returned as nil and true.
+func evalJSONPath(v any, steps []jsonPathStep) (any, bool) {
+	for _, s := range steps {
+		switch t := v.(type) {
+		case map[string]any:
+			if s.IsIndex {
+				return nil, false
+			}
+			var ok bool
+			if v, ok = t[s.Key]; !ok {
+				return nil, false
+			}
+		case []any:
+			index := s.Index
+			if index < 0 {
+				index += len(t)
+			}
+			if !s.IsIndex || index < 0 || index >= len(t) {
+				return nil, false
+			}
+			v = t[index]
+		default:
+			return nil, false
+		}
+	}
+
+	return v, true
+}
+
+// kustoTypeAliases maps the type aliases to their canonical Kusto type.
+var kustoTypeAliases = map[string]string{
+	"boolean":  kustoTypeBool,
+	"date":     kustoTypeDatetime,
+	"uniqueid": kustoTypeGUID,
+	"double":   kustoTypeReal,
+	"time":     kustoTypeTimespan,
+}
+
+// canonicalKustoType returns the lower case canonical type of the type or alias.
+func canonicalKustoType(dataType string) string {
+	dataType = strings.ToLower(dataType)
+	if canonical, ok := kustoTypeAliases[dataType]; ok {
+		return canonical
+	}
+
+	return dataType
+}
+
+func isKnownKustoType(dataType string) bool {
+	switch dataType {
+	case "bool", "boolean", "datetime", "date", "dynamic", "guid", "uniqueid",
+		"int", "long", "real", "double", "decimal", "string", "timespan", "time":
+		return true
+	}
+
+	return false
+}
+
+// canConvertValue reports whether Kusto can convert the record value to the data type.
+// The value is a decoded JSON value (with json.Number for numbers) or a csv field.
+// Null values and empty csv fields are ingested as null for all data types.
+func canConvertValue(dataType string, v any) bool {
+	if v == nil {
+		return true
+	}
+
+	switch dataType {
+	case "string", "dynamic":
+		return true
+	}
+
+	var s string
+	switch t := v.(type) {
+	case string:
+		s = strings.TrimSpace(t)
+		if s == "" {
+			return true
+		}
+	case json.Number:
+		s = t.String()
+	case bool:
+		return dataType == "bool" || dataType == "boolean"
+	default:
+		// objects and arrays only convert to string and dynamic
+		return false
+	}
+
+	switch dataType {
+	case "bool", "boolean":
+		return strings.EqualFold(s, "true") || strings.EqualFold(s, "false") || s == "0" || s == "1"
+	case "int":
+		_, err := strconv.ParseInt(s, 10, 32)
+		return err == nil
+	case "long":
+		_, err := strconv.ParseInt(s, 10, 64)
+		return err == nil
+	case "real", "double", "decimal":
+		_, err := strconv.ParseFloat(s, 64)
+		return err == nil
+	case "datetime", "date":
+		if _, isNumber := v.(json.Number); isNumber {
+			return false
+		}
+		return isDatetimeString(s) || isKustoDatetimeString(s)
+	case "timespan", "time":
+		return timespanPattern.MatchString(s)
+	case "guid", "uniqueid":
+		return guidPattern.MatchString(s)
+	}
+
+	return false
+}
+
+// kustoDatetimeLayouts are the datetime formats Kusto ingests on top of the inferred
+// ones: ISO 8601 without seconds, dates, US dates and RFC 822 / RFC 1123.
+// Fractions of seconds are accepted after the seconds of any layout.
+// ref: https://learn.microsoft.com/en-us/kusto/query/scalar-data-types/datetime
+var kustoDatetimeLayouts = []string{
+	"2006-01-02",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04Z07:00",
+	"2006/01/02",
+	"2006/01/02 15:04:05",
+	"1/2/2006",
+	"1/2/2006 15:04",
+	"1/2/2006 15:04:05",
+	"1/2/2006 3:04:05 PM",
+	time.RFC1123,
+	time.RFC1123Z,
+	time.RFC822,
+	time.RFC822Z,
+	time.RFC850,
+}
+
+func isKustoDatetimeString(s string) bool {
+	for _, layout := range kustoDatetimeLayouts {
+		if _, err := time.Parse(layout, s); err == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// dryRunReport collects the validation results of a single source file.
+type dryRunReport struct {
+	Source  string
+	Records int
+	Invalid int
+
+	out      io.Writer
+	reported int
+	// issues counts the invalid rows per issue, in the order first seen.
+	issues     map[string]int
+	issueOrder []string
+}
+
+// addRowIssues records the issues of a single row.
+func (r *dryRunReport) addRowIssues(location string, issues []dryRunIssue) {
+	r.Records++
+	if len(issues) == 0 {
+		return
+	}
+	r.Invalid++
+
+	seen := map[string]bool{}
+	for _, issue := range issues {
+		if r.reported < dryRunMaxReportedErrors {
+			_, _ = fmt.Fprintf(r.out, "%s: %s: %s\n", r.Source, location, issue.Detail)
+		}
+		r.reported++
+
+		if seen[issue.Summary] {
+			continue
+		}
+		seen[issue.Summary] = true
+		if _, ok := r.issues[issue.Summary]; !ok {
+			r.issueOrder = append(r.issueOrder, issue.Summary)
+		}
+		r.issues[issue.Summary]++
+	}
+}
+
+// Summary returns the summary line, e.g. "98 ok, 2 rows with unparsable datetime in PreciseTimestamp".
+func (r *dryRunReport) Summary() string {
+	parts := []string{fmt.Sprintf("%d ok", r.Records-r.Invalid)}
+	for _, issue := range r.issueOrder {
+		n := r.issues[issue]
+		rows := "rows"
+		if n == 1 {
+			rows = "row"
+		}
+		parts = append(parts, fmt.Sprintf("%d %s with %s", n, rows, issue))
+	}
+	if r.reported > dryRunMaxReportedErrors {
+		parts = append(parts, fmt.Sprintf("%d more errors not printed", r.reported-dryRunMaxReportedErrors))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// dryRunIssue is a validation error of a row.
+type dryRunIssue struct {
+	// Summary groups the issue in the report summary, e.g. "unparsable datetime in PreciseTimestamp".
+	Summary string
+	// Detail is the printed error message.
+	Detail string
+}
+
+// checkRowValues checks the record values of the columns, valueOf returns false
+// when the record doesn't have the value.
+func checkRowValues(columns []dryRunColumn, valueOf func(c dryRunColumn) (any, bool)) []dryRunIssue {
+	var rv []dryRunIssue
+	for _, c := range columns {
+		if c.Skip {
+			continue
+		}
+
+		v, ok := valueOf(c)
+		if !ok {
+			rv = append(rv, dryRunIssue{
+				Summary: fmt.Sprintf("missing %s", c.Name),
+				Detail:  fmt.Sprintf("column %s: missing %s", c.Name, c.Source),
+			})
+			continue
+		}
+		if canConvertValue(c.DataType, v) {
+			continue
+		}
+
+		raw := v
+		if b, err := json.Marshal(v); err == nil {
+			raw = string(b)
+		}
+		rv = append(rv, dryRunIssue{
+			Summary: fmt.Sprintf("unparsable %s in %s", c.DataType, c.Name),
+			Detail:  fmt.Sprintf("column %s: unparsable %s %v", c.Name, c.DataType, raw),
+		})
+	}
+
+	return rv
+}
+
+func decodeJSONRecord(b []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("unexpected data after the JSON value")
+	}
+	return v, nil
+}
+
+// decodeTransformedJSONRecord applies the transform to the record before decoding it,
+// so that the mapping is checked against the ingested record.
+func decodeTransformedJSONRecord(b []byte, transform recordTransform) (any, error) {
+	if !transform.IsEmpty() {
+		var err error
+		if b, err = transform.apply(b); err != nil {
+			return nil, err
+		}
+	}
+
+	return decodeJSONRecord(b)
+}
+
+func invalidRecordIssue(summary string, err error) []dryRunIssue {
+	return []dryRunIssue{{Summary: summary, Detail: fmt.Sprintf("%s: %s", summary, err)}}
+}
+
+// dryRunJSONLines validates line separated JSON records, continuing after invalid lines.
+func dryRunJSONLines(r io.Reader, transform recordTransform, columns []dryRunColumn, report *dryRunReport) error {
+	br := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		b, err := br.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+
+		if len(bytes.TrimSpace(b)) > 0 {
+			location := fmt.Sprintf("line %d", line)
+			v, decodeErr := decodeTransformedJSONRecord(b, transform)
+			if decodeErr != nil {
+				report.addRowIssues(location, invalidRecordIssue("invalid JSON", decodeErr))
+			} else {
+				report.addRowIssues(location, checkRowValues(columns, func(c dryRunColumn) (any, bool) {
+					return evalJSONPath(v, c.Path)
+				}))
+			}
+		}
+
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+	}
+}
+
+// dryRunJSONValues validates concatenated JSON records or JSON array elements.
+// Invalid JSON stops the validation, as the following records can't be located.
+func dryRunJSONValues(r io.Reader, transform recordTransform, columns []dryRunColumn, report *dryRunReport) error {
+	rr, err := newJSONRecordReader(r)
+	if err != nil {
+		report.addRowIssues("record 1", invalidRecordIssue("invalid JSON", err))
+		return nil
+	}
+
+	for {
+		location := fmt.Sprintf("record %d", report.Records+1)
+		b, err := rr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			report.addRowIssues(location, invalidRecordIssue("invalid JSON", err))
+			return nil
+		}
+
+		v, err := decodeTransformedJSONRecord(b, transform)
+		if err != nil {
+			report.addRowIssues(location, invalidRecordIssue("invalid JSON", err))
+			return nil
+		}
+		report.addRowIssues(location, checkRowValues(columns, func(c dryRunColumn) (any, bool) {
+			return evalJSONPath(v, c.Path)
+		}))
+	}
+}
+
+// dryRunDelimited validates delimiter separated records.
+func dryRunDelimited(r io.Reader, delimiter rune, skipHeader bool, columns []dryRunColumn, report *dryRunReport) error {
+	cr := csv.NewReader(r)
+	cr.Comma = delimiter
+	cr.FieldsPerRecord = -1
+
+	header := skipHeader
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			report.addRowIssues(fmt.Sprintf("line %d", parseErr.StartLine), invalidRecordIssue("invalid csv", parseErr.Err))
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if header {
+			header = false
+			continue
+		}
+
+		line, _ := cr.FieldPos(0)
+		report.addRowIssues(fmt.Sprintf("line %d", line), checkRowValues(columns, func(c dryRunColumn) (any, bool) {
+			if c.Ordinal < 0 || c.Ordinal >= len(record) {
+				return nil, false
+			}
+			return record[c.Ordinal], true
+		}))
+	}
+}
+
+// dryRunSourceFile validates a single source file, printing the errors and the summary.
+func (f FileIngestOptions) dryRunSourceFile(cli cli.Provider, sourceFile string) (*dryRunReport, error) {
+	dataFile := sourceFile
+	if sourceFile == stdinSourceFile {
+		spooled, err := spoolToTempFile(cli.Stdin())
+		if err != nil {
+			return nil, fmt.Errorf("read stdin: %w", err)
+		}
+		defer func() { _ = os.Remove(spooled) }()
+		dataFile = spooled
+	}
+
+	compression, err := f.resolveCompression(cli, sourceFile, dataFile)
+	if err != nil {
+		return nil, err
+	}
+	format, err := f.resolveDataFormat(cli, sourceFile, dataFile, compression)
+	if err != nil {
+		return nil, err
+	}
+
+	transform, err := f.Transform.recordTransform()
+	if err != nil {
+		return nil, err
+	}
+	if !transform.IsEmpty() {
+		if err := checkTransformFormat(format); err != nil {
+			return nil, err
+		}
+	}
+
+	var columns []dryRunColumn
+	if f.MappingsFile != "" {
+		columns, err = loadDryRunMapping(f.MappingsFile, format)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	r, err := openDecompressed(dataFile, compression)
+	if err != nil {
+		return nil, fmt.Errorf("open %q: %w", sourceFile, err)
+	}
+	defer func() { _ = r.Close() }()
+
+	report := &dryRunReport{Source: sourceFile, out: cli.Stdout(), issues: map[string]int{}}
+	switch {
+	case format == "json":
+		err = dryRunJSONLines(r, transform, columns, report)
+	case format == "multijson":
+		err = dryRunJSONValues(r, transform, columns, report)
+	case format.delimiter() != 0:
+		err = dryRunDelimited(r, format.delimiter(), f.Properties.IgnoreFirstRecord, columns, report)
+	default:
+		return nil, fmt.Errorf("dry run is not supported for format %q", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read %q: %w", sourceFile, err)
+	}
+
+	_, _ = fmt.Fprintf(report.out, "%s: %s\n", sourceFile, report.Summary())
+	return report, nil
+}
+
+// dryRun validates the source files locally, without connecting to Kusto.
+func (f FileIngestOptions) dryRun(cli cli.Provider, sourceFiles []string) error {
+	if f.MappingRef != "" {
+		cli.Logger().Warn("mapping reference can't be checked offline, only the data format is validated", "mapping", f.MappingRef)
+	}
+
+	var errs []error
+	for _, sourceFile := range sourceFiles {
+		report, err := f.dryRunSourceFile(cli, sourceFile)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("dry run %q: %w", sourceFile, err))
+			continue
+		}
+
+		cli.Logger().Info("dry run completed", "file", sourceFile, "records", report.Records, "invalid", report.Invalid)
+		if report.Invalid > 0 {
+			errs = append(errs, fmt.Errorf("dry run %q: %d of %d rows are invalid", sourceFile, report.Invalid, report.Records))
+		}
+	}
+
+	return errors.Join(errs...)
+}
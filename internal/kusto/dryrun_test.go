@@ -0,0 +1,238 @@
+package kusto
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/Azure/kusto-ingest/internal/cli/testingcli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseJSONPath(t *testing.T) {
+	steps, err := parseJSONPath("$")
+	require.NoError(t, err)
+	assert.Empty(t, steps)
+
+	steps, err = parseJSONPath(`$.a['b.c']["d"][1].e`)
+	require.NoError(t, err)
+	assert.Equal(t, []jsonPathStep{
+		{Key: "a"},
+		{Key: "b.c"},
+		{Key: "d"},
+		{Index: 1, IsIndex: true},
+		{Key: "e"},
+	}, steps)
+
+	for _, invalid := range []string{"a", "$.", "$.*", "$['a'", "$[x]"} {
+		_, err := parseJSONPath(invalid)
+		assert.Error(t, err, "%q should be invalid", invalid)
+	}
+}
+
+func Test_evalJSONPath(t *testing.T) {
+	v, err := decodeJSONRecord([]byte(`{"a": {"b": [1, {"c": "x"}], "null": null}}`))
+	require.NoError(t, err)
+
+	eval := func(path string) (any, bool) {
+		steps, err := parseJSONPath(path)
+		require.NoError(t, err)
+		return evalJSONPath(v, steps)
+	}
+	assertFound := func(expected any, path string) {
+		got, ok := eval(path)
+		assert.True(t, ok, path)
+		assert.Equal(t, expected, got, path)
+	}
+	assertFound("x", "$.a.b[1].c")
+	assertFound(json.Number("1"), "$.a.b[0]")
+	assertFound(nil, "$.a.null")
+	for _, missing := range []string{"$.a.missing", "$.a.b.c", "$.a.b[5]"} {
+		_, ok := eval(missing)
+		assert.False(t, ok, missing)
+	}
+}
+
+func Test_canConvertValue(t *testing.T) {
+	cases := []struct {
+		dataType string
+		value    any
+		expected bool
+	}{
+		{"string", map[string]any{}, true},
+		{"dynamic", "x", true},
+		{"long", nil, true},
+		{"long", "", true},
+		{"long", json.Number("1"), true},
+		{"long", "42", true},
+		{"long", json.Number("1.5"), false},
+		{"int", json.Number("3000000000"), false},
+		{"real", json.Number("1.5"), true},
+		{"real", "abc", false},
+		{"bool", true, true},
+		{"bool", "False", true},
+		{"bool", json.Number("2"), false},
+		{"datetime", "2023-07-25T20:07:00Z", true},
+		{"datetime", "2023-07-25", true},
+		{"datetime", "2024-01-01 10:00:00", true},
+		{"datetime", "2024-01-01 10:00", true},
+		{"datetime", "01/02/2024", true},
+		{"datetime", "1/2/2024 10:00:00.123", true},
+		{"datetime", "2024/01/02 10:00:00", true},
+		{"datetime", "Tue, 02 Jan 2024 10:00:00 GMT", true},
+		{"datetime", "13/25/2024", false},
+		{"datetime", "yesterday", false},
+		{"datetime", json.Number("1690315620"), false},
+		{"timespan", "1.02:03:04.5", true},
+		{"timespan", "1 hour", false},
+		{"guid", "74be27de-1e4e-49d9-b579-fe0b331d3642", true},
+		{"guid", "74be27de", false},
+		{"long", []any{}, false},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.expected, canConvertValue(c.dataType, c.value), "%s <- %#v", c.dataType, c.value)
+	}
+}
+
+func Test_FileIngestOptions_Validate_DryRun(t *testing.T) {
+	opts := FileIngestOptions{Format: "multijson"}
+	err := opts.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "missing flags: --kusto-endpoint, --kusto-database, --kusto-table")
+
+	opts.DryRun = true
+	assert.NoError(t, opts.Validate(), "dry run should not need the Kusto target")
+}
+
+func Test_FileIngestOptions_Run_DryRun(t *testing.T) {
+	newCLI := func(stdout io.Writer) *testingcli.TestProvider {
+		return testingcli.New(func(tp *testingcli.TestProvider) {
+			tp.StdoutFn = func() io.Writer { return stdout }
+		})
+	}
+
+	t.Run("json with mapping", func(t *testing.T) {
+		sourceFile := writeToTestFile(t, "logs.json", []byte(
+			`{"PreciseTimestamp": "2023-07-25T20:07:00Z", "msg": "hello"}
+{"PreciseTimestamp": "not a date", "msg": "hello"}
+
+{"PreciseTimestamp": "2023-07-25T20:07:00Z", "msg": {"nested": true}}
+{"PreciseTimestamp": 
+`))
+
+		stdout := &bytes.Buffer{}
+		opts := FileIngestOptions{
+			SourceFiles:  []string{sourceFile},
+			Format:       "json",
+			MappingsFile: "../../testdata/logs.mapping.json",
+			DryRun:       true,
+		}
+		err := opts.Run(newCLI(stdout))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "2 of 4 rows are invalid")
+
+		output := stdout.String()
+		assert.Contains(t, output, sourceFile+`: line 2: column PreciseTimestamp: unparsable datetime "not a date"`)
+		assert.Contains(t, output, sourceFile+": line 5: invalid JSON: ")
+		assert.Contains(t, output, sourceFile+": 2 ok, 1 row with unparsable datetime in PreciseTimestamp, 1 row with invalid JSON\n")
+	})
+
+	t.Run("multijson without issues", func(t *testing.T) {
+		stdout := &bytes.Buffer{}
+		opts := FileIngestOptions{
+			SourceFiles:  []string{testdataMultiJSON + ".gz"},
+			Format:       "multijson",
+			MappingsFile: "../../testdata/logs.mapping.json",
+			DryRun:       true,
+		}
+		require.NoError(t, opts.Run(newCLI(stdout)))
+		assert.Equal(t, testdataMultiJSON+".gz: 10 ok\n", stdout.String())
+	})
+
+	t.Run("json with transform and mapping", func(t *testing.T) {
+		sourceFile := writeToTestFile(t, "logs.json", []byte(
+			`{"ts": "2023-07-25T20:07:00Z", "msg": "hello", "debug": true}
+{"ts": "not a date", "msg": "hello"}
+{"msg": "hello"}
+`))
+		mappingsFile := writeToTestFile(t, "mapping.json", []byte(`[
+			{"Column": "Timestamp", "DataType": "datetime", "Properties": {"Path": "$.Timestamp"}},
+			{"Column": "Message", "DataType": "string", "Properties": {"Path": "$.msg"}},
+			{"Column": "Environment", "DataType": "string", "Properties": {"Path": "$.env"}},
+			{"Column": "Debug", "DataType": "bool", "Properties": {"Path": "$.debug"}}
+		]`))
+
+		stdout := &bytes.Buffer{}
+		opts := FileIngestOptions{
+			SourceFiles:  []string{sourceFile},
+			Format:       "json",
+			MappingsFile: mappingsFile,
+			DryRun:       true,
+			Transform: TransformOptions{
+				Rename:    []string{"ts=Timestamp"},
+				AddColumn: []string{"env=prod"},
+				Drop:      []string{"debug"},
+			},
+		}
+		err := opts.Run(newCLI(stdout))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "3 of 3 rows are invalid")
+
+		output := stdout.String()
+		assert.Contains(t, output, sourceFile+": line 1: column Debug: missing $.debug")
+		assert.Contains(t, output, sourceFile+`: line 2: column Timestamp: unparsable datetime "not a date"`)
+		assert.Contains(t, output, sourceFile+": line 3: column Timestamp: missing $.Timestamp")
+		assert.NotContains(t, output, "column Environment")
+	})
+
+	t.Run("transform of csv", func(t *testing.T) {
+		sourceFile := writeToTestFile(t, "logs.csv", []byte("a,b\n"))
+		opts := FileIngestOptions{
+			SourceFiles: []string{sourceFile},
+			Format:      "csv",
+			DryRun:      true,
+			Transform:   TransformOptions{Drop: []string{"a"}},
+		}
+		err := opts.Run(newCLI(io.Discard))
+		assert.ErrorContains(t, err, "transformations are only supported for json and multijson sources")
+	})
+
+	t.Run("csv with header", func(t *testing.T) {
+		sourceFile := writeToTestFile(t, "logs.csv", []byte("ts,count\n2023-07-25T20:07:00Z,1\n2023-07-25T20:07:00Z,many\n\"unterminated,1\n"))
+		mappingsFile := writeToTestFile(t, "mapping.json", []byte(`[
+			{"Column": "ts", "DataType": "datetime", "Properties": {"Ordinal": 0}},
+			{"Column": "count", "DataType": "long", "Properties": {"Ordinal": "1"}}
+		]`))
+
+		stdout := &bytes.Buffer{}
+		opts := FileIngestOptions{
+			SourceFiles:  []string{sourceFile},
+			Format:       DataFormatAuto,
+			MappingsFile: mappingsFile,
+			DryRun:       true,
+			Properties:   IngestionPropertiesOptions{IgnoreFirstRecord: true},
+		}
+		err := opts.Run(newCLI(stdout))
+		assert.Error(t, err)
+
+		output := stdout.String()
+		assert.Contains(t, output, sourceFile+`: line 3: column count: unparsable long "many"`)
+		assert.Contains(t, output, sourceFile+": line 4: invalid csv: ")
+		assert.Contains(t, output, sourceFile+": 1 ok, 1 row with unparsable long in count, 1 row with invalid csv\n")
+	})
+
+	t.Run("unsupported format", func(t *testing.T) {
+		sourceFile := writeToTestFile(t, "logs.parquet", []byte("PAR1"))
+		opts := FileIngestOptions{
+			SourceFiles: []string{sourceFile},
+			Format:      "parquet",
+			DryRun:      true,
+		}
+		err := opts.Run(newCLI(io.Discard))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), `dry run is not supported for format "parquet"`)
+	})
+}
@@ -1,46 +1,161 @@
 package kusto
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/Azure/azure-kusto-go/kusto/ingest"
+	"github.com/Azure/azure-kusto-go/kusto/ingest/ingestoptions"
 	"github.com/Azure/kusto-ingest/internal/cli"
 )
 
+func (f FileIngestOptions) Validate() error {
+	if !f.DryRun {
+		if err := f.KustoTarget.Validate(); err != nil {
+			return err
+		}
+	}
+	if mode := f.ingestionMode(); f.Properties.Dedupe && mode != IngestionModeQueued {
+		// managed ingestion streams small sources, which drops the ingest-by: tags
+		return fmt.Errorf("--dedupe is not supported in %s ingestion mode, use --mode=queued", mode)
+	}
+	if flags := f.Properties.queuedOnlyFlags(); len(flags) > 0 && f.ingestionMode() == IngestionModeManaged {
+		return fmt.Errorf("%s: not supported in managed ingestion mode, streaming ingestion of small sources drops them, use --mode=queued", strings.Join(flags, ", "))
+	}
+	if f.MappingsFile != "" && f.ingestionMode() == IngestionModeManaged {
+		// managed ingestion streams small sources, which drops inline mappings
+		return fmt.Errorf("--mappings-file is not supported in managed ingestion mode, use --mapping-ref or --mode=queued")
+	}
+	if f.CheckMapping && f.MappingRef == "" {
+		return fmt.Errorf("--check-mapping requires --mapping-ref")
+	}
+
+	transform, err := f.Transform.recordTransform()
+	if err != nil {
+		return err
+	}
+	if !transform.IsEmpty() && f.Format != DataFormatAuto {
+		if err := checkTransformFormat(f.Format); err != nil {
+			return err
+		}
+	}
+
+	return f.Properties.Validate(f.Format)
+}
+
+func checkTransformFormat(format DataFormatString) error {
+	if format != "json" && format != "multijson" {
+		return fmt.Errorf("transformations are only supported for json and multijson sources, got %q", format)
+	}
+
+	return nil
+}
+
 func (f FileIngestOptions) FileOptions() ([]ingest.FileOption, error) {
+	return f.fileOptions(f.Format, f.Properties)
+}
+
+// fileOptions builds the ingestion options for source data in the given format.
+func (f FileIngestOptions) fileOptions(
+	format DataFormatString,
+	properties IngestionPropertiesOptions,
+) ([]ingest.FileOption, error) {
 	var rv []ingest.FileOption
 
-	fileFormat := f.Format.ToIngestDataFormat()
-	if f.MappingsFile != "" {
+	fileFormat := format.ToIngestDataFormat()
+	switch {
+	case f.MappingRef != "":
+		// same as the inline mapping below, the reference uses the mapping kind
+		// of the data format.
+		mappingKind := format.MappingKind()
+		rv = append(rv, ingest.IngestionMappingRef(f.MappingRef, mappingKind))
+		if mappingKind != fileFormat {
+			rv = append(rv, ingest.FileFormat(fileFormat))
+		}
+	case f.MappingsFile != "":
 		mappingsContent, err := os.ReadFile(f.MappingsFile)
 		if err != nil {
 			return nil, fmt.Errorf("read mappings file %q: %w", f.MappingsFile, err)
 		}
 
-		// when input format is multijson, we need to set the mapping format to json
+		// the mapping kind can differ from the data format, e.g. multijson uses json mappings
+		// and the delimiter separated formats use csv mappings. IngestionMapping sets the data
+		// format to the mapping kind, so we restore the actual data format afterwards.
 		// refs:
 		// - https://learn.microsoft.com/en-us/azure/data-explorer/ingestion-supported-formats
 		// - https://github.com/Azure/azure-kusto-go/blob/2ff486159db0752e13504a58d67fc298e7b61691/kusto/ingest/internal/properties/properties.go#L55
-		ingestDataFormat := fileFormat
-		if ingestDataFormat == ingest.MultiJSON {
-			ingestDataFormat = ingest.JSON
+		mappingKind := format.MappingKind()
+		rv = append(rv, ingest.IngestionMapping(mappingsContent, mappingKind))
+		if mappingKind != fileFormat {
+			rv = append(rv, ingest.FileFormat(fileFormat))
 		}
-		rv = append(rv, ingest.IngestionMapping(mappingsContent, ingestDataFormat))
-	} else {
+	default:
 		rv = append(rv, ingest.FileFormat(fileFormat))
 	}
 
+	propertyOptions, err := properties.FileOptions()
+	if err != nil {
+		return nil, err
+	}
+	rv = append(rv, propertyOptions...)
+
 	return rv, nil
 }
 
+// fileIngestResult is the outcome of ingesting a single source file or chunk.
+type fileIngestResult struct {
+	Source   string
+	Duration time.Duration
+	Err      error
+}
+
+// ingestPayload is the data sent to the ingestor in a single (retried) call.
+type ingestPayload struct {
+	// Name identifies the payload in logs and summaries.
+	Name string
+	// Path is the local file holding the data.
+	Path string
+	// Open opens the data for a single attempt. When nil, Path is passed to
+	// the ingestor as is.
+	Open func() (io.ReadCloser, error)
+	// Options are the ingestion options for the payload.
+	Options []ingest.FileOption
+}
+
 func (f FileIngestOptions) Run(cli cli.Provider) error {
-	cli.Logger().Debug(
-		"file ingestion settings",
-		"source", f.SourceFile,
+	sourceFiles, err := expandSourceFiles(f.SourceFiles, f.Recursive)
+	if err != nil {
+		return err
+	}
+	concurrency := max(f.Concurrency, 1)
+	mode := f.ingestionMode()
+
+	settings := []any{
+		"sources", sourceFiles,
+		"recursive", f.Recursive,
+		"concurrency", concurrency,
+		"mode", mode,
 		"format", f.Format,
+		"compression", f.Compression,
 		"mappings", f.MappingsFile,
+		"mappingRef", f.MappingRef,
+		"checkMapping", f.CheckMapping,
+		"createTableIfMissing", f.CreateTableIfMissing,
+		"dryRun", f.DryRun,
+		"transform.addColumn", f.Transform.AddColumn,
+		"transform.rename", f.Transform.Rename,
+		"transform.drop", f.Transform.Drop,
+		"transform.spec", f.Transform.TransformSpec,
+		"maxChunkBytes", f.MaxChunkBytes,
+		"wait", f.Wait,
+		"waitTimeout", f.WaitTimeout,
 		"target.endpoint", f.KustoTarget.Endpoint,
 		"target.database", f.KustoTarget.Database,
 		"target.table", f.KustoTarget.Table,
@@ -48,43 +163,464 @@ func (f FileIngestOptions) Run(cli cli.Provider) error {
 		"auth.clientID", f.Auth.ClientID,
 		"maxRetries", f.MaxRetries,
 		"maxTimeout", f.MaxTimeout,
-	)
+	}
+	settings = append(settings, f.Properties.settings()...)
+	cli.Logger().Debug("file ingestion settings", settings...)
 
-	fileOptions, err := f.FileOptions()
-	if err != nil {
-		return err
+	if f.DryRun {
+		return f.dryRun(cli, sourceFiles)
+	}
+
+	ctx, cancel := cli.Context()
+	defer cancel()
+
+	if f.CreateTableIfMissing {
+		if err := f.createTableIfMissing(ctx, cli, sourceFiles); err != nil {
+			return err
+		}
+	}
+
+	if f.CheckMapping {
+		if err := f.checkMappingRef(ctx, cli); err != nil {
+			return err
+		}
 	}
 
-	ingestor, err := f.createIngestor(f.KustoTarget, f.Auth)
+	// all files share the same ingestor to avoid re-authenticating per file
+	ingestor, err := f.createIngestor(f.KustoTarget, f.Auth, mode)
 	if err != nil {
 		return fmt.Errorf("create Kusto ingestor: %w", err)
 	}
 	defer func() { _ = ingestor.Close() }()
 
-	ctx, cancel := cli.Context()
-	defer cancel()
+	cli.Logger().Info("file ingestion started", "files", len(sourceFiles), "mode", mode)
+	start := time.Now()
+
+	resultsByFile := make([][]fileIngestResult, len(sourceFiles))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, sourceFile := range sourceFiles {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			resultsByFile[i] = f.ingestSourceFile(ctx, cli, ingestor, mode, sourceFile)
+		}()
+	}
+	wg.Wait()
+
+	var results []fileIngestResult
+	for _, rs := range resultsByFile {
+		results = append(results, rs...)
+	}
+
+	return reportFileIngestResults(cli, results, time.Since(start))
+}
+
+// ingestSourceFile ingests a single source file. The file is ingested as one or
+// more payloads, each with its own retries.
+func (f FileIngestOptions) ingestSourceFile(
+	ctx context.Context,
+	cli cli.Provider,
+	ingestor ingest.Ingestor,
+	mode IngestionMode,
+	sourceFile string,
+) []fileIngestResult {
+	start := time.Now()
+
+	payloads, cleanup, err := f.preparePayloads(cli, mode, sourceFile)
+	defer cleanup()
+	if err != nil {
+		cli.Logger().Error("failed to ingest file", "error", err, "file", sourceFile)
+		return []fileIngestResult{{Source: sourceFile, Duration: time.Since(start), Err: err}}
+	}
+
+	var rv []fileIngestResult
+	for _, payload := range payloads {
+		payloadStart := time.Now()
+		err := f.ingestPayload(ctx, cli, ingestor, payload)
+		result := fileIngestResult{
+			Source:   payload.Name,
+			Duration: time.Since(payloadStart),
+			Err:      err,
+		}
+		rv = append(rv, result)
+
+		if err != nil {
+			cli.Logger().Error("failed to ingest file", "error", err, "file", payload.Name)
+			continue
+		}
+		cli.Logger().Info("file ingested", "file", payload.Name, "duration", result.Duration)
+	}
+
+	return rv
+}
 
+// ingestPayload ingests a single payload with retries. With --wait, it then
+// blocks until Kusto reports the final ingestion status.
+func (f FileIngestOptions) ingestPayload(
+	ctx context.Context,
+	cli cli.Provider,
+	ingestor ingest.Ingestor,
+	payload ingestPayload,
+) error {
+	var result *ingest.Result
 	invokeIngest := func() error {
 		if ctx.Err() != nil {
 			return ctx.Err()
 		}
-		_, err := ingestor.FromFile(ctx, f.SourceFile, fileOptions...)
+
+		var err error
+		if payload.Open == nil {
+			result, err = ingestor.FromFile(ctx, payload.Path, payload.Options...)
+			return err
+		}
+
+		r, err := payload.Open()
+		if err != nil {
+			return fmt.Errorf("open %q: %w", payload.Name, err)
+		}
+		defer func() { _ = r.Close() }()
+
+		result, err = ingestor.FromReader(ctx, r, payload.Options...)
 		return err
 	}
 
-	cli.Logger().Info("file ingestion started")
-	start := time.Now()
-	err = invokeWithRetries(
+	err := invokeWithRetries(
 		invokeIngest,
 		f.MaxRetries,
 		f.MaxTimeout,
 		cli.Logger(),
 	)
-	if err != nil {
-		cli.Logger().Error("failed to ingest file", "error", err, "file", f.SourceFile)
+	if err != nil || !f.Wait {
 		return err
 	}
 
-	cli.Logger().Info("file ingestion completed successfully", "duration", time.Since(start))
+	return f.waitForIngestion(ctx, cli, payload, result)
+}
+
+// waitForIngestion waits for the final ingestion status of the payload, bounded by --wait-timeout.
+func (f FileIngestOptions) waitForIngestion(
+	ctx context.Context,
+	cli cli.Provider,
+	payload ingestPayload,
+	result *ingest.Result,
+) error {
+	waitTimeout := time.Duration(f.WaitTimeout) * time.Second
+	cli.Logger().Info("waiting for ingestion status", "file", payload.Name, "timeout", waitTimeout)
+
+	waitCtx, cancel := context.WithTimeout(ctx, waitTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := f.waitIngestResult(waitCtx, result)
+	if err == nil {
+		cli.Logger().Info("ingestion succeeded", "file", payload.Name, "duration", time.Since(start))
+		return nil
+	}
+
+	if errors.Is(waitCtx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("timed out waiting for ingestion status after %s: %w", waitTimeout, err)
+	}
+
+	if ingest.IsStatusRecord(err) {
+		cli.Logger().Error("ingestion failed", "file", payload.Name, "status", err.Error())
+	}
+	return fmt.Errorf("ingestion failed: %w", err)
+}
+
+// preparePayloads resolves the format and compression of the source file and
+// prepares the payloads to ingest. The returned cleanup function removes any
+// temp files and must be called even when an error is returned.
+func (f FileIngestOptions) preparePayloads(
+	cli cli.Provider,
+	mode IngestionMode,
+	sourceFile string,
+) ([]ingestPayload, func(), error) {
+	var tempPaths []string
+	cleanup := func() {
+		for _, p := range tempPaths {
+			_ = os.RemoveAll(p)
+		}
+	}
+
+	dataFile := sourceFile
+	fromReader := false
+	if sourceFile == stdinSourceFile {
+		// stdin can only be read once, so we spool it to a temp file
+		// and re-open it for each attempt.
+		spooledFile, err := spoolToTempFile(cli.Stdin())
+		if err != nil {
+			return nil, cleanup, fmt.Errorf("read stdin: %w", err)
+		}
+		tempPaths = append(tempPaths, spooledFile)
+
+		dataFile = spooledFile
+		fromReader = true
+	}
+
+	compression, err := f.resolveCompression(cli, sourceFile, dataFile)
+	if err != nil {
+		return nil, cleanup, err
+	}
+
+	format, err := f.resolveDataFormat(cli, sourceFile, dataFile, compression)
+	if err != nil {
+		return nil, cleanup, err
+	}
+	// the csv-only properties are checked for the detected format
+	if err := f.Properties.Validate(format); err != nil {
+		return nil, cleanup, err
+	}
+
+	transform, err := f.Transform.recordTransform()
+	if err != nil {
+		return nil, cleanup, err
+	}
+	if !transform.IsEmpty() {
+		if err := checkTransformFormat(format); err != nil {
+			return nil, cleanup, err
+		}
+	}
+
+	fileOptions, err := f.payloadFileOptions(mode, format, f.Properties)
+	if err != nil {
+		return nil, cleanup, err
+	}
+
+	// dedupeFileOptions adds the content hash of the payload data and the transform as dedupe tag
+	dedupeFileOptions := func(name string, path string) ([]ingest.FileOption, error) {
+		tag, err := contentHashTag(path, transform)
+		if err != nil {
+			return nil, fmt.Errorf("hash %q: %w", name, err)
+		}
+		cli.Logger().Info("dedupe tag computed", "file", name, "tag", tag)
+
+		return f.payloadFileOptions(mode, format, f.Properties.withDedupeTag(tag))
+	}
+
+	chunks, err := f.splitSourceFile(cli, sourceFile, dataFile, compression, format)
+	if err != nil {
+		return nil, cleanup, err
+	}
+	if len(chunks) > 0 {
+		tempPaths = append(tempPaths, filepath.Dir(chunks[0]))
+
+		var payloads []ingestPayload
+		for i, chunk := range chunks {
+			payload := ingestPayload{
+				Name: fmt.Sprintf("%s (chunk %d/%d)", sourceFile, i+1, len(chunks)),
+				Path: chunk,
+				// chunks are written uncompressed
+				Options: fileOptions,
+			}
+			if f.Properties.Dedupe {
+				payload.Options, err = dedupeFileOptions(payload.Name, chunk)
+				if err != nil {
+					return nil, cleanup, err
+				}
+			}
+			if !transform.IsEmpty() {
+				payload.Open = func() (io.ReadCloser, error) {
+					return openTransformed(chunk, CompressionNone, transform)
+				}
+				payload.Options = append(payload.Options, ingest.CompressionType(ingestoptions.GZIP))
+			}
+			payloads = append(payloads, payload)
+		}
+		return payloads, cleanup, nil
+	}
+
+	if f.Properties.Dedupe {
+		fileOptions, err = dedupeFileOptions(sourceFile, dataFile)
+		if err != nil {
+			return nil, cleanup, err
+		}
+	}
+	payload := ingestPayload{
+		Name:    sourceFile,
+		Path:    dataFile,
+		Options: append(fileOptions, compression.FileOptions()...),
+	}
+	switch {
+	case !transform.IsEmpty():
+		// the transformed records are streamed with gzip compression
+		payload.Options = append(fileOptions, ingest.CompressionType(ingestoptions.GZIP))
+		payload.Open = func() (io.ReadCloser, error) {
+			return openTransformed(dataFile, compression, transform)
+		}
+	case compression.NeedsRecompress():
+		payload.Open = func() (io.ReadCloser, error) {
+			return openRecompressed(dataFile, compression)
+		}
+	case fromReader:
+		payload.Open = func() (io.ReadCloser, error) {
+			return os.Open(dataFile)
+		}
+	}
+
+	return []ingestPayload{payload}, cleanup, nil
+}
+
+// payloadFileOptions builds the ingestion options of a payload, checking they're
+// supported by the ingestion mode.
+func (f FileIngestOptions) payloadFileOptions(
+	mode IngestionMode,
+	format DataFormatString,
+	properties IngestionPropertiesOptions,
+) ([]ingest.FileOption, error) {
+	fileOptions, err := f.fileOptions(format, properties)
+	if err != nil {
+		return nil, err
+	}
+	if f.Wait && mode != IngestionModeStreaming {
+		// streaming ingestion is synchronous, so its result is final already
+		fileOptions = append(fileOptions, ingest.ReportResultToTable())
+	}
+	// compression options are supported by all modes, so checking the format options is enough
+	if err := mode.checkFileOptions(fileOptions); err != nil {
+		return nil, err
+	}
+
+	return fileOptions, nil
+}
+
+// splitSourceFile splits the source data into chunks of at most --max-chunk-bytes.
+// Returns no chunks when chunking is disabled or not needed, in which case the
+// source file is ingested as a whole.
+func (f FileIngestOptions) splitSourceFile(
+	cli cli.Provider,
+	sourceFile string,
+	dataFile string,
+	compression CompressionString,
+	format DataFormatString,
+) ([]string, error) {
+	if f.MaxChunkBytes <= 0 {
+		return nil, nil
+	}
+
+	records := format.records()
+	if records == recordsUnsplittable {
+		cli.Logger().Warn("data format doesn't support chunking, ingesting as a whole", "file", sourceFile, "format", format)
+		return nil, nil
+	}
+
+	if compression == CompressionNone {
+		stat, err := os.Stat(dataFile)
+		if err != nil {
+			return nil, fmt.Errorf("stat %q: %w", sourceFile, err)
+		}
+		if stat.Size() <= f.MaxChunkBytes {
+			return nil, nil
+		}
+	}
+
+	r, err := openDecompressed(dataFile, compression)
+	if err != nil {
+		return nil, fmt.Errorf("open %q: %w", sourceFile, err)
+	}
+	defer func() { _ = r.Close() }()
+
+	dir, err := os.MkdirTemp("", "kusto-ingest-chunks-*")
+	if err != nil {
+		return nil, fmt.Errorf("create chunks dir: %w", err)
+	}
+
+	withHeader := f.Properties.IgnoreFirstRecord && records == recordsQuotedLines
+	chunks, err := splitIntoChunks(r, records, f.MaxChunkBytes, withHeader, dir)
+	if err != nil || len(chunks) == 0 {
+		_ = os.RemoveAll(dir)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("split %q into chunks: %w", sourceFile, err)
+	}
+
+	cli.Logger().Debug("split file into chunks", "file", sourceFile, "chunks", len(chunks))
+	return chunks, nil
+}
+
+func (f FileIngestOptions) ingestionMode() IngestionMode {
+	if f.Mode == "" {
+		return IngestionModeQueued
+	}
+
+	return f.Mode
+}
+
+// resolveCompression returns the compression of the source file, detecting it
+// from the file name and content of dataFile when --compression=auto.
+func (f FileIngestOptions) resolveCompression(
+	cli cli.Provider,
+	sourceFile string,
+	dataFile string,
+) (CompressionString, error) {
+	if f.Compression != "" && f.Compression != CompressionAuto {
+		return f.Compression, nil
+	}
+
+	compression, err := detectCompression(sourceFile, dataFile)
+	if err != nil {
+		return "", err
+	}
+
+	cli.Logger().Debug("detected compression", "file", sourceFile, "compression", compression)
+	return compression, nil
+}
+
+// resolveDataFormat returns the data format of the source file, detecting it
+// from the file name and content of dataFile when --format=auto.
+func (f FileIngestOptions) resolveDataFormat(
+	cli cli.Provider,
+	sourceFile string,
+	dataFile string,
+	compression CompressionString,
+) (DataFormatString, error) {
+	if f.Format != DataFormatAuto {
+		return f.Format, nil
+	}
+
+	format, err := detectDataFormat(sourceFile, dataFile, compression)
+	if err != nil {
+		return "", err
+	}
+
+	cli.Logger().Debug("detected data format", "file", sourceFile, "format", format)
+	return format, nil
+}
+
+// reportFileIngestResults logs the summary of the ingestion and returns an error
+// listing the failed files, if any.
+func reportFileIngestResults(cli cli.Provider, results []fileIngestResult, duration time.Duration) error {
+	var (
+		succeeded []string
+		failed    []string
+		errs      []error
+	)
+	for _, r := range results {
+		if r.Err != nil {
+			failed = append(failed, r.Source)
+			errs = append(errs, fmt.Errorf("ingest %q: %w", r.Source, r.Err))
+			continue
+		}
+		succeeded = append(succeeded, r.Source)
+	}
+
+	cli.Logger().Info(
+		"file ingestion summary",
+		"succeeded", len(succeeded),
+		"failed", len(failed),
+		"duration", duration,
+	)
+	if len(succeeded) > 0 {
+		cli.Logger().Info("succeeded files", "files", succeeded)
+	}
+	if len(failed) > 0 {
+		cli.Logger().Error("failed files", "files", failed)
+		return errors.Join(errs...)
+	}
+
+	cli.Logger().Info("file ingestion completed successfully", "duration", duration)
 	return nil
 }
@@ -2,10 +2,15 @@ package kusto
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 
+	kustoerrors "github.com/Azure/azure-kusto-go/kusto/data/errors"
 	"github.com/Azure/azure-kusto-go/kusto/ingest"
 	"github.com/Azure/kusto-ingest/internal/cli/testingcli"
 	"github.com/Azure/kusto-ingest/internal/kusto/testingkusto"
@@ -38,6 +43,39 @@ func Test_FileIngestOptions_FileOptions(t *testing.T) {
 		assert.NotEmpty(t, fileOptions)
 	})
 
+	t.Run("with mapping kind different from data format", func(t *testing.T) {
+		mappingFile := writeToTestFile(t, "test-mapping.json", []byte(`[]`))
+
+		for _, format := range []DataFormatString{"multijson", "tsv", "apacheavro"} {
+			options := FileIngestOptions{
+				Format:       format,
+				MappingsFile: mappingFile,
+			}
+
+			fileOptions, err := options.FileOptions()
+			assert.NoError(t, err)
+			if assert.Len(t, fileOptions, 2, "%q should set both mapping and format", format) {
+				assert.Equal(t, "IngestionMapping", fmt.Sprint(fileOptions[0]))
+				assert.Equal(t, "FileFormat", fmt.Sprint(fileOptions[1]))
+			}
+		}
+	})
+
+	t.Run("with mapping ref", func(t *testing.T) {
+		for _, format := range []DataFormatString{"csv", "multijson"} {
+			options := FileIngestOptions{
+				Format:     format,
+				MappingRef: "logs_mapping",
+			}
+
+			fileOptions, err := options.FileOptions()
+			assert.NoError(t, err)
+			if assert.NotEmpty(t, fileOptions) {
+				assert.Equal(t, "IngestionMappingRef", fmt.Sprint(fileOptions[0]))
+			}
+		}
+	})
+
 	t.Run("with invalid mapping file", func(t *testing.T) {
 		options := FileIngestOptions{
 			Format:       "csv",
@@ -74,7 +112,7 @@ func Test_FileIngestOptions_Run_IngestFile_NoMapping(t *testing.T) {
 	})
 
 	opts := FileIngestOptions{
-		SourceFile:  sourceFile,
+		SourceFiles: []string{sourceFile},
 		Format:      "multijson",
 		Auth:        newTestAuth(),
 		KustoTarget: newTestKustoTarget(),
@@ -106,7 +144,7 @@ func Test_FileIngestOptions_Run_IngestFile_WithMapping(t *testing.T) {
 	})
 
 	opts := FileIngestOptions{
-		SourceFile:   sourceFile,
+		SourceFiles:  []string{sourceFile},
 		Format:       "multijson",
 		MappingsFile: sourceFileMapping,
 		Auth:         newTestAuth(),
@@ -128,7 +166,7 @@ func Test_FileIngestOptions_Run_CreateIngestorError(t *testing.T) {
 	cli := testingcli.New()
 
 	opts := FileIngestOptions{
-		SourceFile:  sourceFile,
+		SourceFiles: []string{sourceFile},
 		Format:      "multijson",
 		Auth:        newTestAuth(),
 		KustoTarget: newTestKustoTarget(),
@@ -143,3 +181,105 @@ func Test_FileIngestOptions_Run_CreateIngestorError(t *testing.T) {
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "create Kusto ingestor")
 }
+
+func Test_FileIngestOptions_Run_IngestStdin(t *testing.T) {
+	content := `{"msg": "hello, stdin"}`
+	cli := testingcli.New(func(tp *testingcli.TestProvider) {
+		tp.StdinFn = func() io.Reader {
+			return strings.NewReader(content)
+		}
+	})
+
+	var received []string
+	ingestor := testingkusto.New(func(ing *testingkusto.Ingestor) {
+		ing.FromFileFunc = func(ctx context.Context, fPath string, options ...ingest.FileOption) (*ingest.Result, error) {
+			t.Fatalf("stdin source should not be ingested via FromFile")
+			return nil, nil
+		}
+		ing.FromReaderFunc = func(ctx context.Context, reader io.Reader, options ...ingest.FileOption) (*ingest.Result, error) {
+			assert.NotEmpty(t, options)
+
+			b, err := io.ReadAll(reader)
+			require.NoError(t, err)
+			received = append(received, string(b))
+
+			if len(received) == 1 {
+				// fail the first attempt to verify the stream can be replayed
+				return nil, kustoerrors.ES(kustoerrors.OpFileIngest, kustoerrors.KTimeout, "request timed out")
+			}
+			return &ingest.Result{}, nil
+		}
+	})
+
+	opts := FileIngestOptions{
+		SourceFiles: []string{stdinSourceFile},
+		Format:      "multijson",
+		Auth:        newTestAuth(),
+		KustoTarget: newTestKustoTarget(),
+		MaxRetries:  1,
+		MaxTimeout:  10,
+
+		ingestorBuildSettings: ingestorBuildSettings{
+			CreateIngestor: func(target KustoTargetOptions, auth AuthOptions) (ingest.Ingestor, error) {
+				return ingestor, nil
+			},
+		},
+	}
+
+	err := opts.Run(cli)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{content, content}, received)
+}
+
+func Test_FileIngestOptions_Run_IngestMultipleFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.multijson", "b.multijson", "c.multijson"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("{}"), 0640))
+	}
+	failedFile := filepath.Join(dir, "b.multijson")
+
+	cli := testingcli.New()
+
+	var (
+		mu       sync.Mutex
+		ingested []string
+	)
+	createIngestorCalls := 0
+	ingestor := testingkusto.New(func(ing *testingkusto.Ingestor) {
+		ing.FromFileFunc = func(ctx context.Context, fPath string, options ...ingest.FileOption) (*ingest.Result, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			ingested = append(ingested, fPath)
+
+			if fPath == failedFile {
+				return nil, assert.AnError
+			}
+			return &ingest.Result{}, nil
+		}
+	})
+
+	opts := FileIngestOptions{
+		SourceFiles: []string{filepath.Join(dir, "*.multijson")},
+		Format:      "multijson",
+		Concurrency: 2,
+		Auth:        newTestAuth(),
+		KustoTarget: newTestKustoTarget(),
+
+		ingestorBuildSettings: ingestorBuildSettings{
+			CreateIngestor: func(target KustoTargetOptions, auth AuthOptions) (ingest.Ingestor, error) {
+				createIngestorCalls++
+				return ingestor, nil
+			},
+		},
+	}
+
+	err := opts.Run(cli)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), failedFile)
+	assert.Equal(t, 1, createIngestorCalls, "ingestor should be shared by all files")
+	assert.ElementsMatch(t, []string{
+		filepath.Join(dir, "a.multijson"),
+		filepath.Join(dir, "b.multijson"),
+		filepath.Join(dir, "c.multijson"),
+	}, ingested)
+}
@@ -0,0 +1,119 @@
+package kusto
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// formatSniffSize is the number of leading bytes read for content based format detection.
+const formatSniffSize = 64 * 1024
+
+var (
+	parquetMagic = []byte("PAR1")
+	avroMagic    = []byte("Obj\x01")
+	orcMagic     = []byte("ORC")
+)
+
+// detectDataFormat detects the data format of the file at path. The format is picked
+// from the file extension of name (ignoring compression suffixes like .gz); when the
+// extension is missing or ambiguous (e.g. .json), the leading bytes of the decompressed
+// content are sniffed instead.
+func detectDataFormat(name string, path string, compression CompressionString) (DataFormatString, error) {
+	ext, _ := splitFileExtensions(name)
+	if format, ok := dataFormatByExtension(ext); ok {
+		return format, nil
+	}
+
+	head, err := readFileHead(path, compression)
+	if err != nil {
+		return "", fmt.Errorf("read %q for format detection: %w", name, err)
+	}
+
+	if format, ok := sniffDataFormat(head); ok {
+		return format, nil
+	}
+
+	return "", fmt.Errorf("unable to detect data format of %q, please specify --format", name)
+}
+
+// splitFileExtensions returns the data extension and the compression extension of the file name.
+// For example, "logs.json.gz" returns ".json" and ".gz".
+func splitFileExtensions(name string) (ext string, compression string) {
+	name = strings.ToLower(filepath.Base(name))
+	ext = filepath.Ext(name)
+	if _, ok := compressionByExtension(ext); ok {
+		compression = ext
+		ext = filepath.Ext(strings.TrimSuffix(name, compression))
+	}
+
+	return ext, compression
+}
+
+func dataFormatByExtension(ext string) (DataFormatString, bool) {
+	if ext == "" {
+		return "", false
+	}
+
+	for _, d := range supportedDataFormats {
+		for _, e := range d.Extensions {
+			if e == ext {
+				return d.Name, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// readFileHead reads the leading bytes of the decompressed file content.
+func readFileHead(path string, compression CompressionString) ([]byte, error) {
+	r, err := openDecompressed(path, compression)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = r.Close() }()
+
+	head, err := io.ReadAll(io.LimitReader(r, formatSniffSize))
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+
+	return head, nil
+}
+
+// sniffDataFormat detects the data format from the leading bytes of the content.
+func sniffDataFormat(head []byte) (DataFormatString, bool) {
+	switch {
+	case bytes.HasPrefix(head, parquetMagic):
+		return "parquet", true
+	case bytes.HasPrefix(head, avroMagic):
+		return "avro", true
+	case bytes.HasPrefix(head, orcMagic):
+		return "orc", true
+	}
+
+	trimmed := bytes.TrimLeft(head, " \t\r\n\ufeff")
+	if len(trimmed) == 0 {
+		return "", false
+	}
+
+	switch trimmed[0] {
+	case '[':
+		// JSON array of records
+		return "multijson", true
+	case '{':
+		// newline-delimited JSON when the first line is a complete object,
+		// otherwise the objects span multiple lines.
+		firstLine, _, _ := bytes.Cut(trimmed, []byte("\n"))
+		if json.Valid(firstLine) {
+			return "json", true
+		}
+		return "multijson", true
+	}
+
+	return "", false
+}
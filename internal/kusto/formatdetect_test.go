@@ -0,0 +1,87 @@
+package kusto
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func gzipBytes(t testing.TB, content []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	return buf.Bytes()
+}
+
+func zipBytes(t testing.TB, name string, content []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	f, err := w.Create(name)
+	require.NoError(t, err)
+	_, err = f.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	return buf.Bytes()
+}
+
+func Test_detectDataFormat(t *testing.T) {
+	jsonLines := []byte("{\"a\": 1}\n{\"a\": 2}\n")
+	jsonMultiLine := []byte("{\n  \"a\": 1\n}\n{\n  \"a\": 2\n}\n")
+	jsonArray := []byte("[{\"a\": 1}, {\"a\": 2}]")
+
+	cases := []struct {
+		name     string
+		fileName string
+		content  []byte
+		expected DataFormatString
+	}{
+		{name: "csv extension", fileName: "logs.csv", content: []byte("a,b\n"), expected: "csv"},
+		{name: "tsv extension", fileName: "logs.TSV", content: []byte("a\tb\n"), expected: "tsv"},
+		{name: "multijson extension", fileName: "logs.multijson", content: jsonLines, expected: "multijson"},
+		{name: "ndjson extension", fileName: "logs.ndjson", content: jsonLines, expected: "json"},
+		{name: "parquet extension", fileName: "logs.parquet", content: []byte("PAR1"), expected: "parquet"},
+		{name: "avro extension", fileName: "logs.avro", content: []byte("Obj\x01"), expected: "avro"},
+		{name: "compressed csv extension", fileName: "logs.csv.gz", content: gzipBytes(t, []byte("a,b\n")), expected: "csv"},
+		{name: "json lines", fileName: "logs.json", content: jsonLines, expected: "json"},
+		{name: "json multi-line objects", fileName: "logs.json", content: jsonMultiLine, expected: "multijson"},
+		{name: "json array", fileName: "logs.json", content: jsonArray, expected: "multijson"},
+		{name: "gzip json array", fileName: "logs.json.gz", content: gzipBytes(t, jsonArray), expected: "multijson"},
+		{name: "zip json lines", fileName: "logs.json.zip", content: zipBytes(t, "logs.json", jsonLines), expected: "json"},
+		{name: "parquet magic", fileName: "data", content: []byte("PAR1\x15\x04"), expected: "parquet"},
+		{name: "avro magic", fileName: "data", content: []byte("Obj\x01\x04\x14"), expected: "avro"},
+		{name: "gzip magic json lines", fileName: "data", content: gzipBytes(t, jsonLines), expected: "json"},
+		{name: "orc magic", fileName: "data", content: []byte("ORC\x0a"), expected: "orc"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p := writeToTestFile(t, c.fileName, c.content)
+
+			compression, err := detectCompression(c.fileName, p)
+			require.NoError(t, err)
+
+			format, err := detectDataFormat(c.fileName, p, compression)
+			require.NoError(t, err)
+			assert.Equal(t, c.expected, format)
+		})
+	}
+
+	t.Run("undetectable", func(t *testing.T) {
+		p := writeToTestFile(t, "data", []byte("hello, world"))
+
+		_, err := detectDataFormat("data", p, CompressionNone)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,201 @@
+package kusto
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Azure/kusto-ingest/internal/cli"
+)
+
+// commandClass classifies the effect of a management command.
+type commandClass string
+
+const (
+	commandReadOnly    commandClass = "read-only"
+	commandAdditive    commandClass = "additive"
+	commandAlter       commandClass = "alter"
+	commandDestructive commandClass = "destructive"
+)
+
+// severity orders the classes from the least to the most harmful.
+func (c commandClass) severity() int {
+	switch c {
+	case commandReadOnly:
+		return 0
+	case commandAdditive:
+		return 1
+	case commandAlter:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// destructiveVerbs delete data or entities, matched as prefix of the command verb
+// so that e.g. .drop-pretend and .purge-cleanup are included. Renames break the
+// queries and policies referencing the old name.
+var destructiveVerbs = []string{".drop", ".purge", ".clear", ".delete", ".set-or-replace", ".detach", ".rename"}
+
+// alterTableSchema matches `.alter table T (...)`, which replaces the table schema
+// and drops the columns that are not listed.
+var alterTableSchema = regexp.MustCompile(`^\.alter\s+table\s+(\[[^\]]*\]|[^\s(]+)\s*\(`)
+
+// additiveVerbs create entities or append data without changing existing ones.
+var additiveVerbs = []string{".create", ".create-merge", ".alter-merge", ".add", ".append", ".set-or-append", ".set", ".ingest"}
+
+// classifyStatement classifies the management command by its verb. Commands that
+// are not known as read-only, additive or destructive are classified as alter,
+// including statements that don't start with a command verb.
+// A `.execute database script` is classified by its most harmful command.
+func classifyStatement(text string) commandClass {
+	text = strings.ToLower(strings.TrimSpace(text))
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return commandReadOnly
+	}
+
+	verb := fields[0]
+	switch {
+	case verb == ".show":
+		return commandReadOnly
+	case isExecuteScript(text):
+		return classifyExecuteScript(text)
+	case verb == ".alter" && len(fields) > 1 && (fields[1] == "column" || fields[1] == "columns"):
+		// column type changes convert or lose the existing data
+		return commandDestructive
+	case alterTableSchema.MatchString(text):
+		return commandDestructive
+	case (verb == ".alter" || verb == ".alter-merge") && isRetentionPolicy(fields):
+		// a shorter soft delete period deletes the older data
+		return commandDestructive
+	case verb == ".alter-merge" && slices.Contains(fields, "policy"):
+		// merged policy properties override the existing ones
+		return commandAlter
+	}
+
+	for _, v := range destructiveVerbs {
+		if strings.HasPrefix(verb, v) {
+			return commandDestructive
+		}
+	}
+	for _, v := range additiveVerbs {
+		if verb == v {
+			return commandAdditive
+		}
+	}
+
+	return commandAlter
+}
+
+// isRetentionPolicy reports whether the command fields alter a retention policy.
+func isRetentionPolicy(fields []string) bool {
+	i := slices.Index(fields, "policy")
+	return i >= 0 && i+1 < len(fields) && fields[i+1] == "retention"
+}
+
+// classifyExecuteScript classifies the commands after the `<|` of the script.
+func classifyExecuteScript(text string) commandClass {
+	_, script, ok := strings.Cut(text, "<|")
+	if !ok {
+		return commandAlter
+	}
+	// the script commands are separated by blank lines or command lines
+	statements, err := splitManagementScript(script)
+	if err != nil || len(statements) == 0 {
+		return commandAlter
+	}
+
+	rv := commandReadOnly
+	for _, s := range statements {
+		if c := classifyStatement(s.Text); c.severity() > rv.severity() {
+			rv = c
+		}
+	}
+
+	return rv
+}
+
+// writeStatementsPlan writes the statements with their classification.
+func writeStatementsPlan(w io.Writer, statements []mgmtStatement) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "LINE\tCLASS\tCOMMAND")
+	for _, s := range statements {
+		_, _ = fmt.Fprintf(tw, "%d\t%s\t%s\n", s.Line, classifyStatement(s.Text), firstLine(s.Text))
+	}
+
+	return tw.Flush()
+}
+
+// firstLine returns the first line of the text, marking that it continues.
+func firstLine(text string) string {
+	line, rest, ok := strings.Cut(text, "\n")
+	if ok && strings.TrimSpace(rest) != "" {
+		return line + " ..."
+	}
+
+	return line
+}
+
+// confirmDestructive checks the destructive statements may run: they are allowed
+// with --allow-destructive, or after confirmation when the user can be prompted.
+func confirmDestructive(cli cli.Provider, statements []mgmtStatement, allowDestructive bool) error {
+	var destructive []string
+	for _, s := range statements {
+		if classifyStatement(s.Text) == commandDestructive {
+			destructive = append(destructive, fmt.Sprintf("line %d: %s", s.Line, firstLine(s.Text)))
+		}
+	}
+
+	return confirmDestructiveCommands(cli, destructive, allowDestructive)
+}
+
+// confirmDestructiveCommands checks the described destructive commands may run.
+func confirmDestructiveCommands(cli cli.Provider, destructive []string, allowDestructive bool) error {
+	if len(destructive) == 0 || allowDestructive {
+		return nil
+	}
+
+	if !cli.IsInteractive() {
+		return fmt.Errorf(
+			"refusing to run %d destructive commands without --allow-destructive:\n%s",
+			len(destructive), strings.Join(destructive, "\n"),
+		)
+	}
+
+	_, _ = fmt.Fprintf(cli.Stderr(), "There are %d destructive commands:\n", len(destructive))
+	for _, line := range destructive {
+		_, _ = fmt.Fprintf(cli.Stderr(), "  %s\n", line)
+	}
+
+	confirmed, err := promptConfirm(cli, "Execute them?")
+	if err != nil {
+		return err
+	}
+	if !confirmed {
+		return fmt.Errorf("destructive commands not confirmed")
+	}
+
+	return nil
+}
+
+// promptConfirm asks the yes/no question on stderr and reads the answer from stdin.
+func promptConfirm(cli cli.Provider, question string) (bool, error) {
+	_, _ = fmt.Fprintf(cli.Stderr(), "%s [y/N] ", question)
+
+	answer, err := bufio.NewReader(cli.Stdin()).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, fmt.Errorf("read confirmation: %w", err)
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
@@ -0,0 +1,102 @@
+package kusto
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/Azure/kusto-ingest/internal/cli/testingcli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_classifyStatement(t *testing.T) {
+	cases := map[string]commandClass{
+		".show tables":                           commandReadOnly,
+		".SHOW table Logs schema":                commandReadOnly,
+		"Logs | take 10":                         commandAlter,
+		"":                                       commandReadOnly,
+		".create table Logs (msg: string)":       commandAdditive,
+		".create-merge table Logs (msg: string)": commandAdditive,
+		".alter-merge table Logs (level: string)":                                             commandAdditive,
+		".add database Logs viewers ('aadapp=test')":                                          commandAdditive,
+		".set-or-append Logs <| OtherLogs":                                                    commandAdditive,
+		".ingest inline into table Logs <| hello":                                             commandAdditive,
+		".alter table Logs policy retention @'{}'":                                            commandDestructive,
+		".alter-merge table Logs policy retention softdelete = 1d":                            commandDestructive,
+		".alter-merge database Logs policy retention recoverability = disabled":               commandDestructive,
+		".alter-merge table Logs policy streamingingestion enable":                            commandAlter,
+		".alter table Logs policy caching hot = 7d":                                           commandAlter,
+		".create-or-alter function F() { Logs }":                                              commandAlter,
+		".alter table Logs docstring 'logs'":                                                  commandAlter,
+		".alter table Logs (msg: string, level: int)":                                         commandDestructive,
+		".alter table ['My Logs'](msg: string)":                                               commandDestructive,
+		".alter column Logs.level type=string":                                                commandDestructive,
+		".alter columns ['Logs'].level type=string":                                           commandDestructive,
+		".rename table Logs to OldLogs":                                                       commandDestructive,
+		".rename column Logs.msg to Message":                                                  commandDestructive,
+		".drop table Logs":                                                                    commandDestructive,
+		".Drop Database Logs":                                                                 commandDestructive,
+		".drop-pretend table Logs extents":                                                    commandDestructive,
+		".purge table Logs records <| where msg == 'secret'":                                  commandDestructive,
+		".clear table Logs data":                                                              commandDestructive,
+		".delete table Logs records <| Logs | where level == 0":                               commandDestructive,
+		".set-or-replace Logs <| OtherLogs":                                                   commandDestructive,
+		".execute database script <|\n.create table A (x: int)\n.alter table A docstring 'a'": commandAlter,
+		".execute database script <|\n.create table A (x: int)\n.drop table B":                commandDestructive,
+	}
+
+	for text, expected := range cases {
+		assert.Equal(t, expected, classifyStatement(text), text)
+	}
+}
+
+func Test_writeStatementsPlan(t *testing.T) {
+	statements, err := splitManagementScript(`.show tables
+.create-merge table Logs (
+    msg: string
+)
+.drop table OldLogs
+`)
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, writeStatementsPlan(buf, statements))
+	assert.Equal(t, `LINE  CLASS        COMMAND
+1     read-only    .show tables
+2     additive     .create-merge table Logs ( ...
+5     destructive  .drop table OldLogs
+`, buf.String())
+}
+
+func Test_confirmDestructive(t *testing.T) {
+	statements := []mgmtStatement{
+		{Line: 1, Text: ".create table Logs (msg: string)"},
+		{Line: 2, Text: ".drop table OldLogs"},
+	}
+
+	newCLI := func(interactive bool, answer string, stderr io.Writer) *testingcli.TestProvider {
+		return testingcli.New(func(tp *testingcli.TestProvider) {
+			tp.IsInteractiveFn = func() bool { return interactive }
+			tp.StdinFn = func() io.Reader { return strings.NewReader(answer) }
+			tp.StderrFn = func() io.Writer { return stderr }
+		})
+	}
+
+	assert.NoError(t, confirmDestructive(newCLI(false, "", io.Discard), statements[:1], false))
+	assert.NoError(t, confirmDestructive(newCLI(false, "", io.Discard), statements, true))
+
+	err := confirmDestructive(newCLI(false, "", io.Discard), statements, false)
+	assert.ErrorContains(t, err, "refusing to run 1 destructive commands without --allow-destructive")
+	assert.ErrorContains(t, err, "line 2: .drop table OldLogs")
+
+	stderr := &bytes.Buffer{}
+	assert.NoError(t, confirmDestructive(newCLI(true, "y\n", stderr), statements, false))
+	assert.Contains(t, stderr.String(), "line 2: .drop table OldLogs")
+	assert.Contains(t, stderr.String(), "Execute them? [y/N]")
+
+	assert.NoError(t, confirmDestructive(newCLI(true, "YES", io.Discard), statements, false))
+	assert.ErrorContains(t, confirmDestructive(newCLI(true, "\n", io.Discard), statements, false), "destructive commands not confirmed")
+	assert.ErrorContains(t, confirmDestructive(newCLI(true, "", io.Discard), statements, false), "destructive commands not confirmed")
+}
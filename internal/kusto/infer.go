@@ -0,0 +1,490 @@
+package kusto
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/kusto-ingest/internal/cli"
+)
+
+// Kusto scalar data types, the schema inference produces datetime, long, real,
+// bool, string and dynamic.
+// ref: https://learn.microsoft.com/en-us/kusto/query/scalar-data-types/
+const (
+	kustoTypeDatetime = "datetime"
+	kustoTypeLong     = "long"
+	kustoTypeReal     = "real"
+	kustoTypeBool     = "bool"
+	kustoTypeString   = "string"
+	kustoTypeDynamic  = "dynamic"
+	kustoTypeInt      = "int"
+	kustoTypeTimespan = "timespan"
+	kustoTypeGUID     = "guid"
+)
+
+const (
+	// csvHeaderAuto detects whether the first csv record is a header.
+	csvHeaderAuto = "auto"
+	// csvHeaderPresent means the first csv record is a header.
+	csvHeaderPresent = "present"
+	// csvHeaderAbsent means the first csv record is data.
+	csvHeaderAbsent = "absent"
+)
+
+const (
+	// nestedDynamic keeps nested JSON objects as a single dynamic column.
+	nestedDynamic = "dynamic"
+	// nestedFlatten maps each leaf of nested JSON objects to its own column.
+	nestedFlatten = "flatten"
+)
+
+// inferDatetimeLayouts are the layouts of string values inferred as datetime.
+var inferDatetimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+}
+
+var jsonPathIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// inferredColumn is a column inferred from the sample records.
+type inferredColumn struct {
+	// Name is the column name.
+	Name string
+	// Path is the JSONPath of the value, for JSON sources.
+	Path string
+	// Ordinal is the field index of the value, for csv sources.
+	Ordinal int
+	// Type is the Kusto data type, widened across all the sampled values.
+	Type string
+	// Observed are the distinct data types of the sampled values, in the order seen.
+	Observed []string
+}
+
+// Widened reports whether the sampled values had mixed data types.
+func (c *inferredColumn) Widened() bool {
+	return len(c.Observed) > 1
+}
+
+func (c *inferredColumn) observe(kustoType string) {
+	if kustoType == "" {
+		// null values don't affect the data type
+		return
+	}
+
+	if !slices.Contains(c.Observed, kustoType) {
+		c.Observed = append(c.Observed, kustoType)
+	}
+	c.Type = widenKustoType(c.Type, kustoType)
+}
+
+// widenKustoType returns the data type which can hold values of both a and b.
+func widenKustoType(a, b string) string {
+	switch {
+	case a == "" || a == b:
+		return b
+	case b == "":
+		return a
+	case a == kustoTypeDynamic || b == kustoTypeDynamic:
+		return kustoTypeDynamic
+	case (a == kustoTypeLong && b == kustoTypeReal) || (a == kustoTypeReal && b == kustoTypeLong):
+		return kustoTypeReal
+	default:
+		return kustoTypeString
+	}
+}
+
+// inferredSchema is the set of columns inferred from the sample records,
+// in the order first seen.
+type inferredSchema struct {
+	Columns []*inferredColumn
+	// Records is the number of sampled records.
+	Records int
+
+	byKey map[string]*inferredColumn
+}
+
+func (s *inferredSchema) column(key string, create func() *inferredColumn) *inferredColumn {
+	if s.byKey == nil {
+		s.byKey = map[string]*inferredColumn{}
+	}
+	if c, ok := s.byKey[key]; ok {
+		return c
+	}
+
+	c := create()
+	s.byKey[key] = c
+	s.Columns = append(s.Columns, c)
+	return c
+}
+
+// finalize assigns the default data type to columns with only null values,
+// and makes the column names unique.
+func (s *inferredSchema) finalize(defaultType string) {
+	seen := map[string]int{}
+	for _, c := range s.Columns {
+		if c.Type == "" {
+			c.Type = defaultType
+		}
+
+		seen[c.Name]++
+		if n := seen[c.Name]; n > 1 {
+			c.Name = fmt.Sprintf("%s_%d", c.Name, n)
+		}
+	}
+}
+
+// inferSchemaOptions configures the schema inference.
+type inferSchemaOptions struct {
+	// SampleSize is the maximum number of records to sample.
+	SampleSize int
+	// Nested is how nested JSON objects are mapped, nestedDynamic or nestedFlatten.
+	Nested string
+	// Header is whether the first csv record is a header with the column names,
+	// csvHeaderAuto, csvHeaderPresent or csvHeaderAbsent.
+	Header string
+}
+
+// canInferSchema reports whether the schema inference supports the data format.
+func canInferSchema(format DataFormatString) bool {
+	return format == "json" || format == "multijson" || format == "csv"
+}
+
+// inferSchema infers the columns from the first records of r in the given format.
+func inferSchema(r io.Reader, format DataFormatString, opts inferSchemaOptions) (*inferredSchema, error) {
+	switch format {
+	case "json", "multijson":
+		return inferJSONSchema(r, opts)
+	case "csv":
+		return inferCSVSchema(r, opts)
+	default:
+		return nil, errSchemaInferenceFormat(format)
+	}
+}
+
+func errSchemaInferenceFormat(format DataFormatString) error {
+	return fmt.Errorf("schema inference is not supported for format %q, supported: json, multijson, csv", format)
+}
+
+func inferJSONSchema(r io.Reader, opts inferSchemaOptions) (*inferredSchema, error) {
+	rr, err := newJSONRecordReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("read record: %w", err)
+	}
+
+	schema := &inferredSchema{}
+	for opts.SampleSize <= 0 || schema.Records < opts.SampleSize {
+		record, err := rr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read record %d: %w", schema.Records+1, err)
+		}
+		schema.Records++
+
+		fields, err := decodeJSONObject(record)
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", schema.Records, err)
+		}
+		if err := schema.observeJSONFields(fields, "", "$", opts.Nested); err != nil {
+			return nil, fmt.Errorf("record %d: %w", schema.Records, err)
+		}
+	}
+
+	schema.finalize(kustoTypeDynamic)
+	return schema, nil
+}
+
+func (s *inferredSchema) observeJSONFields(fields []jsonField, namePrefix string, pathPrefix string, nested string) error {
+	for _, field := range fields {
+		name := namePrefix + field.Key
+		path := pathPrefix + jsonPathSegment(field.Key)
+
+		if nested == nestedFlatten && jsonValueKind(field.Value) == '{' {
+			children, err := decodeJSONObject(field.Value)
+			if err != nil {
+				return err
+			}
+			if len(children) > 0 {
+				if err := s.observeJSONFields(children, name+"_", path, nested); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		c := s.column(path, func() *inferredColumn {
+			return &inferredColumn{Name: name, Path: path}
+		})
+		c.observe(inferJSONValueType(field.Value))
+	}
+
+	return nil
+}
+
+// jsonPathSegment returns the JSONPath child segment for the object key.
+func jsonPathSegment(key string) string {
+	if jsonPathIdentifier.MatchString(key) {
+		return "." + key
+	}
+
+	escaped := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(key)
+	return "['" + escaped + "']"
+}
+
+// jsonField is an object member, in the order it appears in the source.
+type jsonField struct {
+	Key   string
+	Value json.RawMessage
+}
+
+// decodeJSONObject decodes the members of a JSON object, keeping the source order.
+func decodeJSONObject(raw []byte) ([]jsonField, error) {
+	if jsonValueKind(raw) != '{' {
+		return nil, fmt.Errorf("expected a JSON object")
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	// consume the opening brace
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+
+	var rv []jsonField
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := t.(string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected object key %v", t)
+		}
+
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		rv = append(rv, jsonField{Key: key, Value: value})
+	}
+
+	return rv, nil
+}
+
+// jsonValueKind returns the leading byte of the JSON value.
+func jsonValueKind(raw []byte) byte {
+	trimmed := bytes.TrimLeft(raw, " \t\r\n")
+	if len(trimmed) == 0 {
+		return 0
+	}
+	return trimmed[0]
+}
+
+// inferJSONValueType returns the Kusto data type of a JSON value, or "" for null.
+func inferJSONValueType(raw json.RawMessage) string {
+	switch jsonValueKind(raw) {
+	case 'n':
+		return ""
+	case 't', 'f':
+		return kustoTypeBool
+	case '{', '[':
+		return kustoTypeDynamic
+	case '"':
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return kustoTypeString
+		}
+		if isDatetimeString(s) {
+			return kustoTypeDatetime
+		}
+		return kustoTypeString
+	default:
+		if _, err := strconv.ParseInt(string(bytes.TrimSpace(raw)), 10, 64); err == nil {
+			return kustoTypeLong
+		}
+		return kustoTypeReal
+	}
+}
+
+func isDatetimeString(s string) bool {
+	for _, layout := range inferDatetimeLayouts {
+		if _, err := time.Parse(layout, s); err == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+func inferCSVSchema(r io.Reader, opts inferSchemaOptions) (*inferredSchema, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	// the header record is read on top of the sampled records
+	var records [][]string
+	for opts.SampleSize <= 0 || len(records) < opts.SampleSize+1 {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read record %d: %w", len(records)+1, err)
+		}
+		records = append(records, record)
+	}
+
+	var header []string
+	if len(records) > 0 && hasCSVHeader(records, opts.Header) {
+		header = records[0]
+		records = records[1:]
+	} else if opts.SampleSize > 0 && len(records) > opts.SampleSize {
+		records = records[:opts.SampleSize]
+	}
+
+	schema := &inferredSchema{}
+	columnAt := func(ordinal int) *inferredColumn {
+		return schema.column(strconv.Itoa(ordinal), func() *inferredColumn {
+			name := ""
+			if ordinal < len(header) {
+				name = strings.TrimSpace(header[ordinal])
+			}
+			if name == "" {
+				name = fmt.Sprintf("Column%d", ordinal+1)
+			}
+			return &inferredColumn{Name: name, Ordinal: ordinal}
+		})
+	}
+	// header columns are kept even without values
+	for i := range header {
+		columnAt(i)
+	}
+
+	for _, record := range records {
+		schema.Records++
+		for i, v := range record {
+			columnAt(i).observe(inferCSVValueType(v))
+		}
+	}
+
+	// the columns are created out of order when records are wider than the header
+	slices.SortStableFunc(schema.Columns, func(a, b *inferredColumn) int {
+		return a.Ordinal - b.Ordinal
+	})
+
+	schema.finalize(kustoTypeString)
+	return schema, nil
+}
+
+// hasCSVHeader reports whether the first record is a header. With csvHeaderAuto,
+// the first record is a header when all its fields are non-empty strings, i.e.
+// none of them looks like a number, bool or datetime value.
+func hasCSVHeader(records [][]string, header string) bool {
+	switch header {
+	case csvHeaderPresent:
+		return true
+	case csvHeaderAbsent:
+		return false
+	}
+
+	for _, v := range records[0] {
+		if inferCSVValueType(v) != kustoTypeString {
+			return false
+		}
+	}
+
+	return true
+}
+
+// inferCSVValueType returns the Kusto data type of a csv field, or "" for empty fields.
+func inferCSVValueType(v string) string {
+	v = strings.TrimSpace(v)
+	switch {
+	case v == "":
+		return ""
+	case strings.EqualFold(v, "true") || strings.EqualFold(v, "false"):
+		return kustoTypeBool
+	}
+
+	if _, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return kustoTypeLong
+	}
+	// ParseFloat also accepts words like "NaN" and "Inf", which are kept as strings
+	if _, err := strconv.ParseFloat(v, 64); err == nil && strings.ContainsAny(v, "0123456789") {
+		return kustoTypeReal
+	}
+	if isDatetimeString(v) {
+		return kustoTypeDatetime
+	}
+
+	return kustoTypeString
+}
+
+// inferSchemaFromFile infers the columns from the first records of the source
+// file. Stdin ("-"), compressed sources and --format=auto are handled the same
+// way as in file ingestion. Returns the schema and the resolved data format.
+func inferSchemaFromFile(
+	cli cli.Provider,
+	sourceFile string,
+	format DataFormatString,
+	compression CompressionString,
+	opts inferSchemaOptions,
+) (*inferredSchema, DataFormatString, error) {
+	dataFile := sourceFile
+	if sourceFile == stdinSourceFile {
+		spooled, err := spoolToTempFile(cli.Stdin())
+		if err != nil {
+			return nil, "", fmt.Errorf("read stdin: %w", err)
+		}
+		defer func() { _ = os.Remove(spooled) }()
+		dataFile = spooled
+	}
+
+	if compression == "" || compression == CompressionAuto {
+		var err error
+		compression, err = detectCompression(sourceFile, dataFile)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	if format == DataFormatAuto {
+		var err error
+		format, err = detectDataFormat(sourceFile, dataFile, compression)
+		if err != nil {
+			return nil, "", err
+		}
+		cli.Logger().Debug("detected data format", "file", sourceFile, "format", format)
+	}
+	if !canInferSchema(format) {
+		return nil, "", errSchemaInferenceFormat(format)
+	}
+
+	r, err := openDecompressed(dataFile, compression)
+	if err != nil {
+		return nil, "", fmt.Errorf("open %q: %w", sourceFile, err)
+	}
+	defer func() { _ = r.Close() }()
+
+	schema, err := inferSchema(r, format, opts)
+	if err != nil {
+		return nil, "", fmt.Errorf("infer schema of %q: %w", sourceFile, err)
+	}
+	if len(schema.Columns) == 0 {
+		return nil, "", fmt.Errorf("no columns found in %q", sourceFile)
+	}
+
+	cli.Logger().Debug("inferred schema", "file", sourceFile, "records", schema.Records, "columns", len(schema.Columns))
+	return schema, format, nil
+}
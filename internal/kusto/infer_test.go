@@ -0,0 +1,156 @@
+package kusto
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_widenKustoType(t *testing.T) {
+	cases := []struct {
+		a, b     string
+		expected string
+	}{
+		{"", kustoTypeLong, kustoTypeLong},
+		{kustoTypeLong, kustoTypeLong, kustoTypeLong},
+		{kustoTypeLong, kustoTypeReal, kustoTypeReal},
+		{kustoTypeReal, kustoTypeLong, kustoTypeReal},
+		{kustoTypeLong, kustoTypeString, kustoTypeString},
+		{kustoTypeDatetime, kustoTypeBool, kustoTypeString},
+		{kustoTypeString, kustoTypeDynamic, kustoTypeDynamic},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.expected, widenKustoType(c.a, c.b), "%q + %q", c.a, c.b)
+	}
+}
+
+func Test_inferSchema_JSON(t *testing.T) {
+	source := `
+{"ts": "2023-07-25T20:07:00Z", "n": 1, "f": 1.5, "ok": true, "msg": "hi", "tags": ["a"], "props": {"k": "v", "n": 1}, "empty": null}
+{"ts": "2023-07-25T21:07:00Z", "n": 2, "f": 2, "ok": false, "msg": "there", "tags": [], "props": {"k": "v2"}, "empty": null}
+`
+
+	t.Run("nested dynamic", func(t *testing.T) {
+		schema, err := inferSchema(strings.NewReader(source), "json", inferSchemaOptions{Nested: nestedDynamic})
+		require.NoError(t, err)
+		assert.Equal(t, 2, schema.Records)
+
+		var actual [][3]string
+		for _, c := range schema.Columns {
+			actual = append(actual, [3]string{c.Name, c.Path, c.Type})
+		}
+		assert.Equal(t, [][3]string{
+			{"ts", "$.ts", kustoTypeDatetime},
+			{"n", "$.n", kustoTypeLong},
+			{"f", "$.f", kustoTypeReal},
+			{"ok", "$.ok", kustoTypeBool},
+			{"msg", "$.msg", kustoTypeString},
+			{"tags", "$.tags", kustoTypeDynamic},
+			{"props", "$.props", kustoTypeDynamic},
+			{"empty", "$.empty", kustoTypeDynamic},
+		}, actual)
+	})
+
+	t.Run("nested flatten", func(t *testing.T) {
+		schema, err := inferSchema(strings.NewReader(source), "json", inferSchemaOptions{Nested: nestedFlatten})
+		require.NoError(t, err)
+
+		var names, paths []string
+		for _, c := range schema.Columns {
+			names = append(names, c.Name)
+			paths = append(paths, c.Path)
+		}
+		assert.Equal(t, []string{"ts", "n", "f", "ok", "msg", "tags", "props_k", "props_n", "empty"}, names)
+		assert.Contains(t, paths, "$.props.k")
+		assert.Contains(t, paths, "$.props.n")
+	})
+
+	t.Run("json array with sample size", func(t *testing.T) {
+		schema, err := inferSchema(strings.NewReader(`[{"a": 1}, {"a": "x"}, {"b": 1}]`), "multijson", inferSchemaOptions{SampleSize: 2})
+		require.NoError(t, err)
+		assert.Equal(t, 2, schema.Records)
+		require.Len(t, schema.Columns, 1)
+		assert.Equal(t, kustoTypeString, schema.Columns[0].Type)
+		assert.True(t, schema.Columns[0].Widened())
+		assert.Equal(t, []string{kustoTypeLong, kustoTypeString}, schema.Columns[0].Observed)
+	})
+
+	t.Run("not an object", func(t *testing.T) {
+		_, err := inferSchema(strings.NewReader(`[1, 2]`), "multijson", inferSchemaOptions{})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "record 1: expected a JSON object")
+	})
+}
+
+func Test_jsonPathSegment(t *testing.T) {
+	assert.Equal(t, ".msg", jsonPathSegment("msg"))
+	assert.Equal(t, "['@timestamp']", jsonPathSegment("@timestamp"))
+	assert.Equal(t, `['it\'s']`, jsonPathSegment("it's"))
+}
+
+func Test_inferSchema_CSV(t *testing.T) {
+	withHeader := "ts,level,count\n2024-01-01T00:00:00Z,info,1\n2024-01-01T00:00:01Z,warn,2.5\n"
+	withoutHeader := "2024-01-01T00:00:00Z,info,1\n2024-01-01T00:00:01Z,warn,2\n"
+
+	cases := []struct {
+		name          string
+		source        string
+		header        string
+		expectedNames []string
+		expectedTypes []string
+	}{
+		{
+			name:          "auto with header",
+			source:        withHeader,
+			header:        csvHeaderAuto,
+			expectedNames: []string{"ts", "level", "count"},
+			expectedTypes: []string{kustoTypeDatetime, kustoTypeString, kustoTypeReal},
+		},
+		{
+			name:          "auto without header",
+			source:        withoutHeader,
+			header:        csvHeaderAuto,
+			expectedNames: []string{"Column1", "Column2", "Column3"},
+			expectedTypes: []string{kustoTypeDatetime, kustoTypeString, kustoTypeLong},
+		},
+		{
+			name:          "absent",
+			source:        withHeader,
+			header:        csvHeaderAbsent,
+			expectedNames: []string{"Column1", "Column2", "Column3"},
+			expectedTypes: []string{kustoTypeString, kustoTypeString, kustoTypeString},
+		},
+		{
+			name:          "present with records wider than header",
+			source:        "a\n1,true\n",
+			header:        csvHeaderPresent,
+			expectedNames: []string{"a", "Column2"},
+			expectedTypes: []string{kustoTypeLong, kustoTypeBool},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			schema, err := inferSchema(strings.NewReader(c.source), "csv", inferSchemaOptions{Header: c.header})
+			require.NoError(t, err)
+
+			var names, types []string
+			for i, col := range schema.Columns {
+				assert.Equal(t, i, col.Ordinal)
+				names = append(names, col.Name)
+				types = append(types, col.Type)
+			}
+			assert.Equal(t, c.expectedNames, names)
+			assert.Equal(t, c.expectedTypes, types)
+		})
+	}
+}
+
+func Test_inferSchema_UnsupportedFormat(t *testing.T) {
+	_, err := inferSchema(strings.NewReader(""), "parquet", inferSchemaOptions{})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "schema inference is not supported")
+}
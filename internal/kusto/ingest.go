@@ -1,10 +1,85 @@
 package kusto
 
 import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
 	"github.com/Azure/azure-kusto-go/kusto"
 	"github.com/Azure/azure-kusto-go/kusto/ingest"
 )
 
+// IngestionMode is the mode used for ingesting data to Kusto.
+type IngestionMode string
+
+const (
+	// IngestionModeQueued uploads the data to a blob and queues it for ingestion.
+	// This is the most reliable mode, with a latency of minutes.
+	IngestionModeQueued IngestionMode = "queued"
+	// IngestionModeStreaming sends the data directly to the Kusto engine, with low latency.
+	// It requires streaming ingestion to be enabled on the table and is limited to 4MB per request.
+	IngestionModeStreaming IngestionMode = "streaming"
+	// IngestionModeManaged tries streaming ingestion first and falls back to queued ingestion
+	// for large payloads or on failures.
+	IngestionModeManaged IngestionMode = "managed"
+)
+
+func (m IngestionMode) clientScope() ingest.ClientScope {
+	switch m {
+	case IngestionModeStreaming:
+		return ingest.StreamingClient
+	case IngestionModeManaged:
+		return ingest.ManagedClient
+	default:
+		return ingest.QueuedClient
+	}
+}
+
+// managedDroppedFileOptions are the options that the managed client accepts, but
+// drops when it streams a small payload.
+var managedDroppedFileOptions = []string{"IngestionMapping"}
+
+// checkFileOptions checks that the ingestion options are supported by the ingestion mode.
+func (m IngestionMode) checkFileOptions(options []ingest.FileOption) error {
+	for _, opt := range options {
+		if opt.ClientScopes()&m.clientScope() == 0 {
+			return fmt.Errorf("%s is not supported in %s ingestion mode", opt, m)
+		}
+		if m == IngestionModeManaged && slices.Contains(managedDroppedFileOptions, opt.String()) {
+			return fmt.Errorf("%s is not supported in %s ingestion mode, streaming ingestion of small payloads drops it", opt, m)
+		}
+	}
+
+	return nil
+}
+
+// Validate checks the required target options are set.
+func (t KustoTargetOptions) Validate() error {
+	return t.validate(true)
+}
+
+// validate checks the required flags, the table is optional for commands
+// working on the whole database.
+func (t KustoTargetOptions) validate(withTable bool) error {
+	var missing []string
+	if t.Endpoint == "" {
+		missing = append(missing, "--kusto-endpoint")
+	}
+	if t.Database == "" {
+		missing = append(missing, "--kusto-database")
+	}
+	if withTable && t.Table == "" {
+		missing = append(missing, "--kusto-table")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing flags: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
 func createKustoClient(
 	target KustoTargetOptions,
 	auth AuthOptions,
@@ -23,8 +98,17 @@ type ingestorBuildSettings struct {
 	CreateQueryClient func(target KustoTargetOptions, auth AuthOptions) (ingest.QueryClient, error)
 
 	// CreateIngestor - optional callback for creating the Kusto ingestor.
-	// Defaults to creating an instance via ingest.New.
+	// Defaults to creating an instance via ingest.New, ingest.NewStreaming or
+	// ingest.NewManaged, depending on the ingestion mode.
 	CreateIngestor func(target KustoTargetOptions, auth AuthOptions) (ingest.Ingestor, error)
+
+	// WaitIngestResult - optional callback for waiting on the final ingestion status.
+	// Defaults to waiting via ingest.Result.Wait.
+	WaitIngestResult func(ctx context.Context, result *ingest.Result) error
+
+	// PollOperationDelay - optional callback for the delay before polling the state
+	// of an async operation. Defaults to exponential backoff from 1s up to 30s.
+	PollOperationDelay func(attempt int) time.Duration
 }
 
 func (s ingestorBuildSettings) createQueryClient(
@@ -45,6 +129,7 @@ func (s ingestorBuildSettings) createQueryClient(
 func (s ingestorBuildSettings) createIngestor(
 	target KustoTargetOptions,
 	auth AuthOptions,
+	mode IngestionMode,
 ) (ingest.Ingestor, error) {
 	if s.CreateIngestor != nil {
 		return s.CreateIngestor(target, auth)
@@ -55,5 +140,23 @@ func (s ingestorBuildSettings) createIngestor(
 		return nil, err
 	}
 
-	return ingest.New(queryClient, target.Database, target.Table)
+	switch mode {
+	case IngestionModeStreaming:
+		return ingest.NewStreaming(queryClient, target.Database, target.Table)
+	case IngestionModeManaged:
+		return ingest.NewManaged(queryClient, target.Database, target.Table)
+	default:
+		return ingest.New(queryClient, target.Database, target.Table)
+	}
+}
+
+func (s ingestorBuildSettings) waitIngestResult(ctx context.Context, result *ingest.Result) error {
+	if s.WaitIngestResult != nil {
+		return s.WaitIngestResult(ctx, result)
+	}
+
+	if result == nil {
+		return nil
+	}
+	return <-result.Wait(ctx)
 }
@@ -0,0 +1,253 @@
+package kusto
+
+import (
+	"context"
+	"testing"
+
+	kustoerrors "github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/ingest"
+	"github.com/Azure/kusto-ingest/internal/cli/testingcli"
+	"github.com/Azure/kusto-ingest/internal/kusto/testingkusto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ingestorBuildSettings_createIngestor(t *testing.T) {
+	settings := ingestorBuildSettings{
+		CreateQueryClient: func(target KustoTargetOptions, auth AuthOptions) (ingest.QueryClient, error) {
+			return testingkusto.NewQueryClient(), nil
+		},
+	}
+
+	cases := []struct {
+		mode     IngestionMode
+		expected ingest.Ingestor
+	}{
+		{mode: "", expected: &ingest.Ingestion{}},
+		{mode: IngestionModeQueued, expected: &ingest.Ingestion{}},
+		{mode: IngestionModeStreaming, expected: &ingest.Streaming{}},
+		{mode: IngestionModeManaged, expected: &ingest.Managed{}},
+	}
+
+	for _, c := range cases {
+		t.Run(string(c.mode), func(t *testing.T) {
+			ingestor, err := settings.createIngestor(newTestKustoTarget(), newTestAuth(), c.mode)
+			require.NoError(t, err)
+			assert.IsType(t, c.expected, ingestor)
+		})
+	}
+}
+
+func Test_IngestionMode_checkFileOptions(t *testing.T) {
+	mappingOptions := []ingest.FileOption{ingest.IngestionMapping("[]", ingest.JSON)}
+
+	assert.NoError(t, IngestionModeQueued.checkFileOptions(mappingOptions))
+	err := IngestionModeManaged.checkFileOptions(mappingOptions)
+	assert.ErrorContains(t, err, "IngestionMapping is not supported in managed ingestion mode")
+
+	err = IngestionModeStreaming.checkFileOptions(mappingOptions)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "IngestionMapping is not supported in streaming ingestion mode")
+
+	assert.NoError(t, IngestionModeStreaming.checkFileOptions([]ingest.FileOption{
+		ingest.FileFormat(ingest.JSON),
+		ingest.IngestionMappingRef("mapping", ingest.JSON),
+	}))
+}
+
+func Test_FileIngestOptions_Run_StreamingMode(t *testing.T) {
+	sourceFile := writeToTestFile(t, "logs.json", []byte("{}"))
+
+	newOpts := func(ingestor ingest.Ingestor) FileIngestOptions {
+		return FileIngestOptions{
+			SourceFiles: []string{sourceFile},
+			Format:      "multijson",
+			Mode:        IngestionModeStreaming,
+			Auth:        newTestAuth(),
+			KustoTarget: newTestKustoTarget(),
+			MaxRetries:  1,
+			MaxTimeout:  10,
+
+			ingestorBuildSettings: ingestorBuildSettings{
+				CreateIngestor: func(target KustoTargetOptions, auth AuthOptions) (ingest.Ingestor, error) {
+					return ingestor, nil
+				},
+			},
+		}
+	}
+
+	t.Run("transient streaming errors are retried", func(t *testing.T) {
+		calls := 0
+		ingestor := testingkusto.New(func(ing *testingkusto.Ingestor) {
+			ing.FromFileFunc = func(ctx context.Context, fPath string, options ...ingest.FileOption) (*ingest.Result, error) {
+				calls++
+				if calls == 1 {
+					return nil, kustoerrors.ES(kustoerrors.OpIngestStream, kustoerrors.KTimeout, "request timed out")
+				}
+				return &ingest.Result{}, nil
+			}
+		})
+
+		err := newOpts(ingestor).Run(testingcli.New())
+		assert.NoError(t, err)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("unsupported options fail before ingestion", func(t *testing.T) {
+		ingestor := testingkusto.New(func(ing *testingkusto.Ingestor) {
+			ing.FromFileFunc = func(ctx context.Context, fPath string, options ...ingest.FileOption) (*ingest.Result, error) {
+				t.Fatalf("ingestion should not be attempted")
+				return nil, nil
+			}
+		})
+
+		opts := newOpts(ingestor)
+		opts.MappingsFile = writeToTestFile(t, "logs-mapping.json", []byte("[]"))
+
+		err := opts.Run(testingcli.New())
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "not supported in streaming ingestion mode")
+	})
+}
+
+func Test_FileIngestOptions_ManagedMode_Dedupe(t *testing.T) {
+	opts := FileIngestOptions{
+		SourceFiles: []string{"logs.json"},
+		Format:      "json",
+		Mode:        IngestionModeManaged,
+		Properties:  IngestionPropertiesOptions{Dedupe: true},
+		Auth:        newTestAuth(),
+		KustoTarget: newTestKustoTarget(),
+	}
+
+	// small sources are streamed in managed mode, which drops the dedupe tags
+	err := opts.Validate()
+	assert.EqualError(t, err, "--dedupe is not supported in managed ingestion mode, use --mode=queued")
+
+	opts.Mode = IngestionModeQueued
+	assert.NoError(t, opts.Validate())
+}
+
+func Test_FileIngestOptions_ManagedMode_MappingsFile(t *testing.T) {
+	opts := FileIngestOptions{
+		SourceFiles:  []string{"logs.json"},
+		Format:       "json",
+		Mode:         IngestionModeManaged,
+		MappingsFile: "../../testdata/logs.mapping.json",
+		Auth:         newTestAuth(),
+		KustoTarget:  newTestKustoTarget(),
+	}
+
+	// small sources are streamed in managed mode, which drops the inline mapping
+	err := opts.Validate()
+	assert.EqualError(t, err, "--mappings-file is not supported in managed ingestion mode, use --mapping-ref or --mode=queued")
+
+	opts.Mode = IngestionModeQueued
+	assert.NoError(t, opts.Validate())
+
+	opts.Mode = IngestionModeManaged
+	opts.MappingsFile = ""
+	opts.MappingRef = "logs_json"
+	assert.NoError(t, opts.Validate())
+}
+
+func Test_FileIngestOptions_Run_DetectedFormatProperties(t *testing.T) {
+	sourceFile := writeToTestFile(t, "logs.json", []byte(`{"msg": "hello"}`))
+	ingestor := testingkusto.New(func(ing *testingkusto.Ingestor) {
+		ing.FromFileFunc = func(ctx context.Context, fPath string, options ...ingest.FileOption) (*ingest.Result, error) {
+			t.Fatalf("ingestion should not be attempted")
+			return nil, nil
+		}
+	})
+
+	opts := FileIngestOptions{
+		SourceFiles: []string{sourceFile},
+		Format:      DataFormatAuto,
+		Properties:  IngestionPropertiesOptions{IgnoreFirstRecord: true},
+		Auth:        newTestAuth(),
+		KustoTarget: newTestKustoTarget(),
+		MaxRetries:  1,
+		MaxTimeout:  10,
+		ingestorBuildSettings: ingestorBuildSettings{
+			CreateIngestor: func(target KustoTargetOptions, auth AuthOptions) (ingest.Ingestor, error) {
+				return ingestor, nil
+			},
+		},
+	}
+	require.NoError(t, opts.Validate(), "csv-only properties can't be checked before the format is detected")
+
+	err := opts.Run(testingcli.New())
+	assert.ErrorContains(t, err, `--ignore-first-record is only supported for csv-like formats, got "json"`)
+}
+
+func Test_FileIngestOptions_Run_Wait(t *testing.T) {
+	sourceFile := writeToTestFile(t, "logs.json", []byte("{}"))
+
+	newOpts := func(waitIngestResult func(ctx context.Context, result *ingest.Result) error) FileIngestOptions {
+		ingestor := testingkusto.New(func(ing *testingkusto.Ingestor) {
+			ing.FromFileFunc = func(ctx context.Context, fPath string, options ...ingest.FileOption) (*ingest.Result, error) {
+				var names []string
+				for _, opt := range options {
+					names = append(names, opt.String())
+				}
+				assert.Contains(t, names, "ReportResultToTable")
+
+				return &ingest.Result{}, nil
+			}
+		})
+
+		return FileIngestOptions{
+			SourceFiles: []string{sourceFile},
+			Format:      "multijson",
+			Auth:        newTestAuth(),
+			KustoTarget: newTestKustoTarget(),
+			Wait:        true,
+			WaitTimeout: 1,
+
+			ingestorBuildSettings: ingestorBuildSettings{
+				CreateIngestor: func(target KustoTargetOptions, auth AuthOptions) (ingest.Ingestor, error) {
+					return ingestor, nil
+				},
+				WaitIngestResult: waitIngestResult,
+			},
+		}
+	}
+
+	t.Run("succeeded", func(t *testing.T) {
+		waited := false
+		opts := newOpts(func(ctx context.Context, result *ingest.Result) error {
+			waited = true
+			return nil
+		})
+
+		err := opts.Run(testingcli.New())
+		assert.NoError(t, err)
+		assert.True(t, waited)
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		opts := newOpts(func(ctx context.Context, result *ingest.Result) error {
+			return ingest.StatusFromMapForTests(map[string]interface{}{
+				"Status":        "Failed",
+				"FailureStatus": "Permanent",
+				"Details":       "Stream_InputStreamTooLarge",
+			})
+		})
+
+		err := opts.Run(testingcli.New())
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "ingestion failed")
+		assert.Contains(t, err.Error(), "Stream_InputStreamTooLarge")
+	})
+
+	t.Run("timed out", func(t *testing.T) {
+		opts := newOpts(func(ctx context.Context, result *ingest.Result) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+
+		err := opts.Run(testingcli.New())
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "timed out waiting for ingestion status")
+	})
+}
@@ -1,13 +1,59 @@
 package kusto
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io"
+	"strings"
 	"time"
 
+	"github.com/Azure/azure-kusto-go/kusto"
+	"github.com/Azure/azure-kusto-go/kusto/ingest"
 	"github.com/Azure/azure-kusto-go/kusto/kql"
 	"github.com/Azure/kusto-ingest/internal/cli"
 )
 
+func (m ManagementOptions) Validate() error {
+	if m.RenderOnly || m.Plan {
+		return nil
+	}
+
+	return m.KustoTarget.Validate()
+}
+
+func (m ManagementOptions) output() string {
+	if m.Output == "" {
+		return resultOutputTable
+	}
+
+	return m.Output
+}
+
+// asyncTimeout is the maximum time to wait for an async operation.
+func (m ManagementOptions) asyncTimeout() time.Duration {
+	if m.AsyncTimeout <= 0 {
+		return time.Hour
+	}
+
+	return time.Duration(m.AsyncTimeout) * time.Second
+}
+
+// render renders the source template with the variables. Sources are sent as is
+// unless templating is enabled, so that `{{` in e.g. function bodies is kept.
+func (m ManagementOptions) render() (string, error) {
+	if !m.Template && len(m.Vars) == 0 && m.VarsFile == "" {
+		return string(m.Source), nil
+	}
+
+	vars, err := loadTemplateVars(m.VarsFile, m.Vars)
+	if err != nil {
+		return "", err
+	}
+
+	return renderTemplate("source", string(m.Source), vars)
+}
+
 func (m ManagementOptions) Run(cli cli.Provider) error {
 	cli.Logger().Debug(
 		"management command settings",
@@ -17,8 +63,39 @@ func (m ManagementOptions) Run(cli cli.Provider) error {
 		"auth.clientID", m.Auth.ClientID,
 		"maxRetries", m.MaxRetries,
 		"maxTimeout", m.MaxTimeout,
+		"template", m.Template,
+		"varsFile", m.VarsFile,
+		"renderOnly", m.RenderOnly,
+		"plan", m.Plan,
+		"allowDestructive", m.AllowDestructive,
+		"output", m.Output,
+		"noWait", m.NoWait,
+		"asyncTimeout", m.AsyncTimeout,
 	)
 
+	source, err := m.render()
+	if err != nil {
+		return err
+	}
+	if m.RenderOnly {
+		_, err := io.WriteString(cli.Stdout(), source)
+		return err
+	}
+
+	statements, err := splitManagementScript(source)
+	if err != nil {
+		return fmt.Errorf("parse management commands: %w", err)
+	}
+	if len(statements) == 0 {
+		return fmt.Errorf("no management commands in source")
+	}
+	if m.Plan {
+		return writeStatementsPlan(cli.Stdout(), statements)
+	}
+	if err := confirmDestructive(cli, statements, m.AllowDestructive); err != nil {
+		return err
+	}
+
 	queryer, err := m.createQueryClient(m.KustoTarget, m.Auth)
 	if err != nil {
 		return fmt.Errorf("create Kusto query client: %w", err)
@@ -28,29 +105,150 @@ func (m ManagementOptions) Run(cli cli.Provider) error {
 	ctx, cancel := cli.Context()
 	defer cancel()
 
-	stmt := kql.New("").AddUnsafe(string(m.Source))
-	invokeQuery := func() error {
-		if ctx.Err() != nil {
-			return ctx.Err()
+	// the results of multiple commands in table and csv outputs are separated by blank lines,
+	// in json output they are nested in a single array with an element per command
+	output := m.output()
+	nestJSON := output == resultOutputJSON && len(statements) > 1
+	separate := false
+	writeResult := func(_ mgmtStatement, iter *kusto.RowIterator) error {
+		result, err := readResultTable(iter)
+		if err != nil {
+			return err
+		}
+		if len(result.Rows) > 0 && (output == resultOutputTable || output == resultOutputCSV) {
+			if separate {
+				_, _ = fmt.Fprintln(cli.Stdout())
+			}
+			separate = true
+		}
+		if nestJSON {
+			if err := writeNestedResultJSON(cli.Stdout(), result, !separate); err != nil {
+				return err
+			}
+			separate = true
+		} else if err := writeResultTable(cli.Stdout(), output, result); err != nil {
+			return err
 		}
-		_, err := queryer.Mgmt(ctx, m.KustoTarget.Database, stmt)
-		return err
-	}
 
-	cli.Logger().Info("executing management command")
+		// async commands return the OperationId immediately, the outcome is polled
+		if operationID, ok := asyncOperationID(result); ok && !m.NoWait {
+			return m.waitForOperation(ctx, cli, queryer, m.KustoTarget.Database, operationID, m.asyncTimeout(), m.MaxRetries, m.MaxTimeout)
+		}
+		return nil
+	}
 
 	start := time.Now()
-	err = invokeWithRetries(
-		invokeQuery,
-		m.MaxRetries,
-		m.MaxTimeout,
-		cli.Logger(),
-	)
+	err = executeMgmtStatements(ctx, cli, queryer, m.KustoTarget.Database, statements, m.MaxRetries, m.MaxTimeout, writeResult)
+	if nestJSON && separate {
+		// close the array of the results written so far, also when a command failed
+		_, _ = io.WriteString(cli.Stdout(), "\n]\n")
+	}
 	if err != nil {
-		cli.Logger().Error("failed to execute management command", "error", err)
 		return err
 	}
 
-	cli.Logger().Info("management command executed successfully", "duration", time.Since(start))
+	cli.Logger().Info("management commands executed successfully", "commands", len(statements), "duration", time.Since(start))
+	return nil
+}
+
+// writeNestedResultJSON writes the result as an element of the outer JSON array,
+// which is opened with the first element.
+func writeNestedResultJSON(w io.Writer, result resultTable, first bool) error {
+	var buf bytes.Buffer
+	if err := writeResultTable(&buf, resultOutputJSON, result); err != nil {
+		return err
+	}
+
+	prefix := ",\n"
+	if first {
+		prefix = "[\n"
+	}
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	_, err := io.WriteString(w, prefix+"  "+strings.Join(lines, "\n  "))
+	return err
+}
+
+// executeMgmtStatements executes the management commands in order, each with its own
+// retries. It stops on the first failing command.
+func executeMgmtStatements(
+	ctx context.Context,
+	cli cli.Provider,
+	queryer ingest.QueryClient,
+	database string,
+	statements []mgmtStatement,
+	maxRetries int,
+	maxTimeout int,
+	onResult func(statement mgmtStatement, iter *kusto.RowIterator) error,
+) error {
+	for i, statement := range statements {
+		logger := cli.Logger().With("command", fmt.Sprintf("%d/%d", i+1, len(statements)), "line", statement.Line)
+		logger.Info("executing management command")
+		logger.Debug("management command", "text", statement.Text)
+
+		start := time.Now()
+		stmt := kql.New("").AddUnsafe(statement.Text)
+		iter, err := executeMgmtResult(ctx, cli, queryer, database, stmt, maxRetries, maxTimeout)
+		if err == nil {
+			if onResult != nil {
+				err = onResult(statement, iter)
+			} else if iter != nil {
+				iter.Stop()
+			}
+		}
+		if err != nil {
+			logger.Error("failed to execute management command", "error", err)
+			return fmt.Errorf("management command at line %d: %w", statement.Line, err)
+		}
+
+		logger.Info("management command executed successfully", "duration", time.Since(start))
+	}
+
 	return nil
 }
+
+// executeMgmt executes the management command with retries for transient errors.
+// The result is discarded.
+func executeMgmt(
+	ctx context.Context,
+	cli cli.Provider,
+	queryer ingest.QueryClient,
+	database string,
+	stmt kusto.Statement,
+	maxRetries int,
+	maxTimeout int,
+) error {
+	iter, err := executeMgmtResult(ctx, cli, queryer, database, stmt, maxRetries, maxTimeout)
+	if iter != nil {
+		iter.Stop()
+	}
+	return err
+}
+
+// executeMgmtResult executes the management command with retries for transient errors
+// and returns the result of the successful attempt.
+func executeMgmtResult(
+	ctx context.Context,
+	cli cli.Provider,
+	queryer ingest.QueryClient,
+	database string,
+	stmt kusto.Statement,
+	maxRetries int,
+	maxTimeout int,
+) (*kusto.RowIterator, error) {
+	var rv *kusto.RowIterator
+	invokeQuery := func() error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		var err error
+		rv, err = queryer.Mgmt(ctx, database, stmt)
+		return err
+	}
+
+	if err := invokeWithRetries(invokeQuery, maxRetries, maxTimeout, cli.Logger()); err != nil {
+		return nil, err
+	}
+
+	return rv, nil
+}
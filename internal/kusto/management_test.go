@@ -1,15 +1,21 @@
 package kusto
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"io"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/Azure/azure-kusto-go/kusto"
 	"github.com/Azure/azure-kusto-go/kusto/ingest"
 	"github.com/Azure/kusto-ingest/internal/cli/testingcli"
 	"github.com/Azure/kusto-ingest/internal/kusto/testingkusto"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func Test_ManagementOptions_Run_Success(t *testing.T) {
@@ -85,3 +91,322 @@ func Test_ManagementOptions_Run_MgmtError(t *testing.T) {
 	// The error will be wrapped by invokeWithRetries as "non-retryable error"
 	assert.Contains(t, err.Error(), "non-retryable kusto error")
 }
+
+func Test_ManagementOptions_Validate(t *testing.T) {
+	opts := ManagementOptions{KustoTarget: KustoTargetOptions{Endpoint: "https://example.kusto.windows.net"}}
+	err := opts.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "missing flags: --kusto-database, --kusto-table")
+
+	opts.KustoTarget = newTestKustoTarget()
+	assert.NoError(t, opts.Validate())
+}
+
+func Test_ManagementOptions_Run_MultipleCommands(t *testing.T) {
+	source := []byte(`.create table A (x: int)
+
+.create table B (x: int)
+.create table C (x: int)
+`)
+
+	run := func(failOn string) ([]string, error) {
+		var executed []string
+		q := testingkusto.NewQueryClient(func(qc *testingkusto.QueryClient) {
+			qc.MgmtFn = func(_ context.Context, db string, stmt kusto.Statement, _ ...kusto.QueryOption) (*kusto.RowIterator, error) {
+				executed = append(executed, stmt.String())
+				if stmt.String() == failOn {
+					return nil, errors.New("mgmt command failed")
+				}
+				return nil, nil
+			}
+		})
+
+		opts := ManagementOptions{
+			Source:      source,
+			Auth:        newTestAuth(),
+			KustoTarget: newTestKustoTarget(),
+			ingestorBuildSettings: ingestorBuildSettings{
+				CreateQueryClient: func(target KustoTargetOptions, auth AuthOptions) (ingest.QueryClient, error) {
+					return q, nil
+				},
+			},
+		}
+		return executed, opts.Run(testingcli.New())
+	}
+
+	t.Run("success", func(t *testing.T) {
+		executed, err := run("")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{
+			".create table A (x: int)",
+			".create table B (x: int)",
+			".create table C (x: int)",
+		}, executed)
+	})
+
+	t.Run("stops on first failure", func(t *testing.T) {
+		executed, err := run(".create table B (x: int)")
+		assert.ErrorContains(t, err, "management command at line 3")
+		assert.Len(t, executed, 2)
+	})
+}
+
+func Test_ManagementOptions_Run_NoCommands(t *testing.T) {
+	opts := ManagementOptions{
+		Source:      []byte("// nothing to do\n"),
+		Auth:        newTestAuth(),
+		KustoTarget: newTestKustoTarget(),
+	}
+	assert.ErrorContains(t, opts.Run(testingcli.New()), "no management commands in source")
+}
+
+func Test_ManagementOptions_Run_Template(t *testing.T) {
+	source := []byte(".create table {{ .table }} (x: int)\n")
+
+	t.Run("render only", func(t *testing.T) {
+		stdout := &bytes.Buffer{}
+		cli := testingcli.New(func(tp *testingcli.TestProvider) {
+			tp.StdoutFn = func() io.Writer { return stdout }
+		})
+
+		opts := ManagementOptions{
+			Source:     source,
+			Vars:       []string{"table=Logs"},
+			RenderOnly: true,
+		}
+		require.NoError(t, opts.Validate())
+		require.NoError(t, opts.Run(cli))
+		assert.Equal(t, ".create table Logs (x: int)\n", stdout.String())
+	})
+
+	t.Run("execute rendered", func(t *testing.T) {
+		var executed []string
+		q := testingkusto.NewQueryClient(func(qc *testingkusto.QueryClient) {
+			qc.MgmtFn = func(_ context.Context, db string, stmt kusto.Statement, _ ...kusto.QueryOption) (*kusto.RowIterator, error) {
+				executed = append(executed, stmt.String())
+				return nil, nil
+			}
+		})
+
+		opts := ManagementOptions{
+			Source:      source,
+			VarsFile:    writeToTestFile(t, "test.vars", []byte("table=Logs\n")),
+			Auth:        newTestAuth(),
+			KustoTarget: newTestKustoTarget(),
+			ingestorBuildSettings: ingestorBuildSettings{
+				CreateQueryClient: func(target KustoTargetOptions, auth AuthOptions) (ingest.QueryClient, error) {
+					return q, nil
+				},
+			},
+		}
+		require.NoError(t, opts.Run(testingcli.New()))
+		assert.Equal(t, []string{".create table Logs (x: int)"}, executed)
+	})
+
+	t.Run("undefined variable", func(t *testing.T) {
+		opts := ManagementOptions{
+			Source:      source,
+			Template:    true,
+			Auth:        newTestAuth(),
+			KustoTarget: newTestKustoTarget(),
+		}
+		assert.ErrorContains(t, opts.Run(testingcli.New()), `map has no entry for key "table"`)
+	})
+
+	t.Run("not a template", func(t *testing.T) {
+		stdout := &bytes.Buffer{}
+		cli := testingcli.New(func(tp *testingcli.TestProvider) {
+			tp.StdoutFn = func() io.Writer { return stdout }
+		})
+
+		opts := ManagementOptions{
+			Source:     []byte(".create-or-alter function F() { print s = '{{ .x }}' }\n"),
+			RenderOnly: true,
+		}
+		require.NoError(t, opts.Run(cli))
+		assert.Equal(t, string(opts.Source), stdout.String(), "should be sent as is without template flags")
+	})
+}
+
+func Test_ManagementOptions_Run_Output(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	cli := testingcli.New(func(tp *testingcli.TestProvider) {
+		tp.StdoutFn = func() io.Writer { return stdout }
+	})
+
+	q := testingkusto.NewQueryClient(func(qc *testingkusto.QueryClient) {
+		qc.MgmtFn = func(_ context.Context, db string, stmt kusto.Statement, _ ...kusto.QueryOption) (*kusto.RowIterator, error) {
+			switch stmt.String() {
+			case ".show tables":
+				return newTestStringRows(t, []string{"TableName", "DatabaseName"}, []string{"A", "TestDatabase"}, []string{"B", "TestDatabase"}), nil
+			case ".show table A ingestion mappings":
+				return newTestMappingRows(t, ingestionMappingRecord{Name: "a_json", Kind: "Json"}), nil
+			default:
+				return nil, nil
+			}
+		}
+	})
+
+	opts := ManagementOptions{
+		Source:      []byte(".show tables\n.create table C (x: int)\n.show table A ingestion mappings\n"),
+		Output:      resultOutputTable,
+		Auth:        newTestAuth(),
+		KustoTarget: newTestKustoTarget(),
+		ingestorBuildSettings: ingestorBuildSettings{
+			CreateQueryClient: func(target KustoTargetOptions, auth AuthOptions) (ingest.QueryClient, error) {
+				return q, nil
+			},
+		},
+	}
+	require.NoError(t, opts.Run(cli))
+	assert.Equal(t, `TableName  DatabaseName
+A          TestDatabase
+B          TestDatabase
+
+Name    Kind  Mapping
+a_json  Json  []
+`, stdout.String())
+
+	stdout.Reset()
+	opts.Output = resultOutputNDJSON
+	require.NoError(t, opts.Run(cli))
+	assert.Equal(t, `{"TableName":"A","DatabaseName":"TestDatabase"}
+{"TableName":"B","DatabaseName":"TestDatabase"}
+{"Name":"a_json","Kind":"Json","Mapping":"[]"}
+`, stdout.String())
+
+	stdout.Reset()
+	opts.Output = resultOutputJSON
+	require.NoError(t, opts.Run(cli))
+	assert.Equal(t, `[
+  [
+    {"TableName":"A","DatabaseName":"TestDatabase"},
+    {"TableName":"B","DatabaseName":"TestDatabase"}
+  ],
+  [],
+  [
+    {"Name":"a_json","Kind":"Json","Mapping":"[]"}
+  ]
+]
+`, stdout.String())
+	var results [][]map[string]any
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &results), "should be a single JSON document")
+	assert.Len(t, results, 3)
+
+	stdout.Reset()
+	opts.Source = []byte(".show tables\n")
+	require.NoError(t, opts.Run(cli))
+	assert.Equal(t, `[
+  {"TableName":"A","DatabaseName":"TestDatabase"},
+  {"TableName":"B","DatabaseName":"TestDatabase"}
+]
+`, stdout.String(), "single command result should not be nested")
+}
+
+func Test_ManagementOptions_Run_Destructive(t *testing.T) {
+	source := []byte(".create table A (x: int)\n.drop table B\n")
+
+	newOpts := func(executed *[]string) ManagementOptions {
+		q := testingkusto.NewQueryClient(func(qc *testingkusto.QueryClient) {
+			qc.MgmtFn = func(_ context.Context, db string, stmt kusto.Statement, _ ...kusto.QueryOption) (*kusto.RowIterator, error) {
+				*executed = append(*executed, stmt.String())
+				return nil, nil
+			}
+		})
+
+		return ManagementOptions{
+			Source:      source,
+			Auth:        newTestAuth(),
+			KustoTarget: newTestKustoTarget(),
+			ingestorBuildSettings: ingestorBuildSettings{
+				CreateQueryClient: func(target KustoTargetOptions, auth AuthOptions) (ingest.QueryClient, error) {
+					return q, nil
+				},
+			},
+		}
+	}
+
+	t.Run("refused", func(t *testing.T) {
+		var executed []string
+		err := newOpts(&executed).Run(testingcli.New())
+		assert.ErrorContains(t, err, "without --allow-destructive")
+		assert.Empty(t, executed, "should not execute any command")
+	})
+
+	t.Run("allowed", func(t *testing.T) {
+		var executed []string
+		opts := newOpts(&executed)
+		opts.AllowDestructive = true
+		require.NoError(t, opts.Run(testingcli.New()))
+		assert.Equal(t, []string{".create table A (x: int)", ".drop table B"}, executed)
+	})
+
+	t.Run("plan", func(t *testing.T) {
+		stdout := &bytes.Buffer{}
+		cli := testingcli.New(func(tp *testingcli.TestProvider) {
+			tp.StdoutFn = func() io.Writer { return stdout }
+		})
+
+		opts := ManagementOptions{Source: source, Plan: true}
+		require.NoError(t, opts.Validate())
+		require.NoError(t, opts.Run(cli))
+		assert.Equal(t, "LINE  CLASS        COMMAND\n1     additive     .create table A (x: int)\n2     destructive  .drop table B\n", stdout.String())
+	})
+}
+
+func Test_ManagementOptions_Run_Async(t *testing.T) {
+	source := []byte(".set-or-append async Logs <| RawLogs\n\n.show tables")
+
+	run := func(t *testing.T, state string, noWait bool) ([]string, error) {
+		var executed []string
+		q := testingkusto.NewQueryClient(func(qc *testingkusto.QueryClient) {
+			qc.MgmtFn = func(_ context.Context, db string, stmt kusto.Statement, _ ...kusto.QueryOption) (*kusto.RowIterator, error) {
+				executed = append(executed, stmt.String())
+				switch {
+				case strings.HasPrefix(stmt.String(), ".set-or-append async"):
+					return newTestStringRows(t, []string{"OperationId"}, []string{testOperationID}), nil
+				case strings.HasPrefix(stmt.String(), ".show operations"):
+					return newTestOperationRows(t, state, "boom"), nil
+				}
+				return nil, nil
+			}
+		})
+
+		opts := ManagementOptions{
+			Source:      source,
+			Output:      resultOutputNone,
+			NoWait:      noWait,
+			Auth:        newTestAuth(),
+			KustoTarget: newTestKustoTarget(),
+			ingestorBuildSettings: ingestorBuildSettings{
+				CreateQueryClient: func(target KustoTargetOptions, auth AuthOptions) (ingest.QueryClient, error) {
+					return q, nil
+				},
+				PollOperationDelay: func(int) time.Duration { return time.Millisecond },
+			},
+		}
+		return executed, opts.Run(testingcli.New())
+	}
+
+	t.Run("completed", func(t *testing.T) {
+		executed, err := run(t, "Completed", false)
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			".set-or-append async Logs <| RawLogs",
+			".show operations " + testOperationID,
+			".show tables",
+		}, executed)
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		executed, err := run(t, "Failed", false)
+		assert.ErrorContains(t, err, "management command at line 1: async operation "+testOperationID+" Failed: boom")
+		assert.Len(t, executed, 2, "should stop after the failed operation")
+	})
+
+	t.Run("no wait", func(t *testing.T) {
+		executed, err := run(t, "Failed", true)
+		require.NoError(t, err)
+		assert.Equal(t, []string{".set-or-append async Logs <| RawLogs", ".show tables"}, executed)
+	})
+}
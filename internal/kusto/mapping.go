@@ -0,0 +1,127 @@
+package kusto
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/ingest"
+	"github.com/Azure/azure-kusto-go/kusto/kql"
+	"github.com/Azure/kusto-ingest/internal/cli"
+)
+
+// ingestionMappingRecord is a row of the `.show table T ingestion mappings` result.
+type ingestionMappingRecord struct {
+	Name string `kusto:"Name"`
+	Kind string `kusto:"Kind"`
+	// Mapping is the mapping JSON.
+	Mapping string `kusto:"Mapping"`
+}
+
+// quoteIdentifier quotes a Kusto entity name, e.g. a table name, as ['name'].
+func quoteIdentifier(name string) string {
+	return "['" + strings.ReplaceAll(name, "'", "\\'") + "']"
+}
+
+// showTableIngestionMappings lists the ingestion mappings of the target table.
+func showTableIngestionMappings(
+	ctx context.Context,
+	queryClient ingest.QueryClient,
+	target KustoTargetOptions,
+) ([]ingestionMappingRecord, error) {
+	stmt := kql.New(".show table ").
+		AddUnsafe(quoteIdentifier(target.Table)).
+		AddLiteral(" ingestion mappings")
+
+	iter, err := queryClient.Mgmt(ctx, target.Database, stmt)
+	if err != nil {
+		return nil, err
+	}
+	if iter == nil {
+		return nil, nil
+	}
+	defer iter.Stop()
+
+	var rv []ingestionMappingRecord
+	err = iter.DoOnRowOrError(func(row *table.Row, inlineErr *errors.Error) error {
+		if inlineErr != nil {
+			return inlineErr
+		}
+
+		var rec ingestionMappingRecord
+		if err := row.ToStruct(&rec); err != nil {
+			return err
+		}
+		rv = append(rv, rec)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return rv, nil
+}
+
+// findMappingRef checks the mapping named name exists in the mappings. When the
+// format is known, the mapping kind must match the mapping kind of the format.
+func findMappingRef(mappings []ingestionMappingRecord, name string, format DataFormatString) error {
+	var kinds []string
+	for _, m := range mappings {
+		if m.Name == name {
+			kinds = append(kinds, m.Kind)
+		}
+	}
+
+	if len(kinds) == 0 {
+		return fmt.Errorf("ingestion mapping %q not found", name)
+	}
+
+	if format == DataFormatAuto {
+		return nil
+	}
+
+	want := format.MappingKind().String()
+	for _, k := range kinds {
+		if strings.EqualFold(k, want) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf(
+		"ingestion mapping %q is of kind %s, but format %q requires a %s mapping",
+		name, strings.Join(kinds, ", "), format, want,
+	)
+}
+
+// checkMappingRef checks the --mapping-ref mapping exists on the target table,
+// so that a missing mapping fails the run before any data is sent.
+func (f FileIngestOptions) checkMappingRef(ctx context.Context, cli cli.Provider) error {
+	queryClient, err := f.createQueryClient(f.KustoTarget, f.Auth)
+	if err != nil {
+		return fmt.Errorf("create Kusto query client: %w", err)
+	}
+	defer func() { _ = queryClient.Close() }()
+
+	var mappings []ingestionMappingRecord
+	invokeShow := func() error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		var err error
+		mappings, err = showTableIngestionMappings(ctx, queryClient, f.KustoTarget)
+		return err
+	}
+	if err := invokeWithRetries(invokeShow, f.MaxRetries, f.MaxTimeout, cli.Logger()); err != nil {
+		return fmt.Errorf("list ingestion mappings of table %q: %w", f.KustoTarget.Table, err)
+	}
+
+	if err := findMappingRef(mappings, f.MappingRef, f.Format); err != nil {
+		return fmt.Errorf("check mapping: %w", err)
+	}
+
+	cli.Logger().Debug("ingestion mapping found", "mapping", f.MappingRef, "table", f.KustoTarget.Table)
+	return nil
+}
@@ -0,0 +1,128 @@
+package kusto
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/types"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/Azure/azure-kusto-go/kusto/ingest"
+	"github.com/Azure/kusto-ingest/internal/cli/testingcli"
+	"github.com/Azure/kusto-ingest/internal/kusto/testingkusto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestStringRows creates a management command result with string columns.
+func newTestStringRows(t testing.TB, columns []string, rows ...[]string) *kusto.RowIterator {
+	t.Helper()
+
+	var cols table.Columns
+	for _, c := range columns {
+		cols = append(cols, table.Column{Name: c, Type: types.String})
+	}
+	mockRows, err := kusto.NewMockRows(cols)
+	require.NoError(t, err)
+
+	for _, row := range rows {
+		var values value.Values
+		for _, v := range row {
+			values = append(values, value.String{Value: v, Valid: true})
+		}
+		require.NoError(t, mockRows.Row(values))
+	}
+
+	iter := &kusto.RowIterator{}
+	require.NoError(t, iter.Mock(mockRows))
+	return iter
+}
+
+// newTestMappingRows creates the `.show table T ingestion mappings` result of the
+// given mappings, the mapping JSON defaults to [].
+func newTestMappingRows(t testing.TB, mappings ...ingestionMappingRecord) *kusto.RowIterator {
+	t.Helper()
+
+	var rows [][]string
+	for _, m := range mappings {
+		mapping := m.Mapping
+		if mapping == "" {
+			mapping = "[]"
+		}
+		rows = append(rows, []string{m.Name, m.Kind, mapping})
+	}
+	return newTestStringRows(t, []string{"Name", "Kind", "Mapping"}, rows...)
+}
+
+func Test_findMappingRef(t *testing.T) {
+	mappings := []ingestionMappingRecord{
+		{Name: "logs_json", Kind: "Json"},
+		{Name: "logs_csv", Kind: "Csv"},
+	}
+
+	assert.NoError(t, findMappingRef(mappings, "logs_json", "multijson"))
+	assert.NoError(t, findMappingRef(mappings, "logs_csv", "tsv"))
+	assert.NoError(t, findMappingRef(mappings, "logs_csv", DataFormatAuto))
+
+	err := findMappingRef(mappings, "missing", "json")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `ingestion mapping "missing" not found`)
+
+	err = findMappingRef(mappings, "logs_csv", "json")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "requires a json mapping")
+}
+
+func Test_FileIngestOptions_Run_CheckMapping(t *testing.T) {
+	sourceFile := writeToTestFile(t, "logs.json", []byte("{}"))
+
+	newOpts := func(mappingRef string, ingested *bool) FileIngestOptions {
+		q := testingkusto.NewQueryClient(func(qc *testingkusto.QueryClient) {
+			qc.MgmtFn = func(_ context.Context, db string, stmt kusto.Statement, _ ...kusto.QueryOption) (*kusto.RowIterator, error) {
+				assert.Equal(t, "TestDatabase", db)
+				assert.Equal(t, ".show table ['TestTable'] ingestion mappings", stmt.String())
+				return newTestMappingRows(t, ingestionMappingRecord{Name: "logs_json", Kind: "Json"}), nil
+			}
+		})
+		ingestor := testingkusto.New(func(ing *testingkusto.Ingestor) {
+			ing.FromFileFunc = func(ctx context.Context, fPath string, options ...ingest.FileOption) (*ingest.Result, error) {
+				*ingested = true
+				return &ingest.Result{}, nil
+			}
+		})
+
+		return FileIngestOptions{
+			SourceFiles:  []string{sourceFile},
+			Format:       "multijson",
+			MappingRef:   mappingRef,
+			CheckMapping: true,
+			Auth:         newTestAuth(),
+			KustoTarget:  newTestKustoTarget(),
+
+			ingestorBuildSettings: ingestorBuildSettings{
+				CreateQueryClient: func(target KustoTargetOptions, auth AuthOptions) (ingest.QueryClient, error) {
+					return q, nil
+				},
+				CreateIngestor: func(target KustoTargetOptions, auth AuthOptions) (ingest.Ingestor, error) {
+					return ingestor, nil
+				},
+			},
+		}
+	}
+
+	t.Run("found", func(t *testing.T) {
+		ingested := false
+		err := newOpts("logs_json", &ingested).Run(testingcli.New())
+		assert.NoError(t, err)
+		assert.True(t, ingested)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		ingested := false
+		err := newOpts("missing", &ingested).Run(testingcli.New())
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), `ingestion mapping "missing" not found`)
+		assert.False(t, ingested, "should fail before ingestion")
+	})
+}
@@ -0,0 +1,116 @@
+package kusto
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/Azure/azure-kusto-go/kusto/ingest"
+	"github.com/Azure/kusto-ingest/internal/cli"
+)
+
+// ingestionMappingEntry is a column of an ingestion mapping, in the format
+// accepted by --mappings-file.
+// ref: https://learn.microsoft.com/en-us/kusto/management/mappings
+type ingestionMappingEntry struct {
+	Column     string                     `json:"Column"`
+	DataType   string                     `json:"DataType"`
+	Properties ingestionMappingProperties `json:"Properties"`
+}
+
+type ingestionMappingProperties struct {
+	Path       string         `json:"Path,omitempty"`
+	Ordinal    mappingOrdinal `json:"Ordinal,omitempty"`
+	Transform  string         `json:"Transform,omitempty"`
+	ConstValue string         `json:"ConstValue,omitempty"`
+}
+
+// mappingOrdinal is the Ordinal property of csv mappings. Kusto accepts both
+// "0" and 0, the former is written.
+type mappingOrdinal string
+
+func (o *mappingOrdinal) UnmarshalJSON(b []byte) error {
+	var n json.Number
+	if err := json.Unmarshal(b, &n); err != nil {
+		return fmt.Errorf("invalid Ordinal %s: %w", b, err)
+	}
+	*o = mappingOrdinal(n)
+	return nil
+}
+
+// buildIngestionMapping builds the ingestion mapping of the inferred columns.
+// JSON formats map columns by path, csv maps them by ordinal.
+func buildIngestionMapping(schema *inferredSchema, format DataFormatString) []ingestionMappingEntry {
+	rv := make([]ingestionMappingEntry, 0, len(schema.Columns))
+	for _, c := range schema.Columns {
+		entry := ingestionMappingEntry{
+			Column:   c.Name,
+			DataType: c.Type,
+		}
+		if format.MappingKind() == ingest.CSV {
+			entry.Properties.Ordinal = mappingOrdinal(strconv.Itoa(c.Ordinal))
+		} else {
+			entry.Properties.Path = c.Path
+		}
+		rv = append(rv, entry)
+	}
+
+	return rv
+}
+
+// writeOutput writes content to the output file, or to stdout when output is empty.
+func writeOutput(cli cli.Provider, output string, content []byte) error {
+	if output == "" {
+		_, err := cli.Stdout().Write(content)
+		return err
+	}
+
+	if err := os.WriteFile(output, content, 0644); err != nil {
+		return fmt.Errorf("write %q: %w", output, err)
+	}
+	return nil
+}
+
+func (m MappingInferOptions) Run(cli cli.Provider) error {
+	cli.Logger().Debug(
+		"mapping infer settings",
+		"source", m.Sample.SourceFile,
+		"format", m.Sample.Format,
+		"compression", m.Sample.Compression,
+		"sampleSize", m.Sample.SampleSize,
+		"nested", m.Sample.Nested,
+		"header", m.Sample.Header,
+		"output", m.Output,
+	)
+
+	schema, format, err := inferSchemaFromFile(
+		cli,
+		m.Sample.SourceFile,
+		m.Sample.Format,
+		m.Sample.Compression,
+		m.Sample.inferSchemaOptions(),
+	)
+	if err != nil {
+		return err
+	}
+
+	content, err := json.MarshalIndent(buildIngestionMapping(schema, format), "", "    ")
+	if err != nil {
+		return fmt.Errorf("encode mapping: %w", err)
+	}
+	content = append(content, '\n')
+
+	if err := writeOutput(cli, m.Output, content); err != nil {
+		return err
+	}
+
+	cli.Logger().Info(
+		"ingestion mapping inferred",
+		"format", format,
+		"kind", format.MappingKind(),
+		"records", schema.Records,
+		"columns", len(schema.Columns),
+	)
+	return nil
+}
@@ -0,0 +1,80 @@
+package kusto
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Azure/kusto-ingest/internal/cli/testingcli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_MappingInferOptions_Run(t *testing.T) {
+	t.Run("multijson to stdout", func(t *testing.T) {
+		stdout := &bytes.Buffer{}
+		cli := testingcli.New(func(tp *testingcli.TestProvider) {
+			tp.StdoutFn = func() io.Writer { return stdout }
+		})
+
+		opts := MappingInferOptions{
+			Sample: SchemaSampleOptions{
+				SourceFile: testdataMultiJSON,
+				Format:     DataFormatAuto,
+				SampleSize: 100,
+				Nested:     nestedDynamic,
+			},
+		}
+		require.NoError(t, opts.Run(cli))
+
+		// the inferred mapping matches the hand written one
+		expected, err := os.ReadFile("../../testdata/logs.mapping.json")
+		require.NoError(t, err)
+		assert.JSONEq(t, string(expected), stdout.String())
+	})
+
+	t.Run("compressed csv to file", func(t *testing.T) {
+		source := writeToTestFile(t, "logs.csv.gz", gzipBytes(t, []byte("ts,msg\n2024-01-01T00:00:00Z,hello\n")))
+		output := filepath.Join(t.TempDir(), "mapping.json")
+
+		opts := MappingInferOptions{
+			Sample: SchemaSampleOptions{
+				SourceFile: source,
+				Format:     DataFormatAuto,
+				Header:     csvHeaderAuto,
+			},
+			Output: output,
+		}
+		require.NoError(t, opts.Run(testingcli.New()))
+
+		content, err := os.ReadFile(output)
+		require.NoError(t, err)
+
+		var mapping []ingestionMappingEntry
+		require.NoError(t, json.Unmarshal(content, &mapping))
+		assert.Equal(t, []ingestionMappingEntry{
+			{Column: "ts", DataType: kustoTypeDatetime, Properties: ingestionMappingProperties{Ordinal: "0"}},
+			{Column: "msg", DataType: kustoTypeString, Properties: ingestionMappingProperties{Ordinal: "1"}},
+		}, mapping)
+
+		// the generated mapping can be used for ingestion directly
+		fileOptions, err := FileIngestOptions{Format: "csv", MappingsFile: output}.FileOptions()
+		assert.NoError(t, err)
+		assert.NotEmpty(t, fileOptions)
+	})
+
+	t.Run("unsupported format", func(t *testing.T) {
+		opts := MappingInferOptions{
+			Sample: SchemaSampleOptions{
+				SourceFile: writeToTestFile(t, "logs.parquet", []byte("PAR1")),
+				Format:     DataFormatAuto,
+			},
+		}
+		err := opts.Run(testingcli.New())
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), `schema inference is not supported for format "parquet"`)
+	})
+}
@@ -0,0 +1,416 @@
+package kusto
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	kustoerrors "github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/ingest"
+	"github.com/Azure/azure-kusto-go/kusto/kql"
+	"github.com/Azure/kusto-ingest/internal/cli"
+)
+
+// migrationFileName matches the NNNN_description.kql migration files.
+var migrationFileName = regexp.MustCompile(`^(\d+)_(.+)\.kql$`)
+
+const (
+	migrationApplied  = "applied"
+	migrationPending  = "pending"
+	migrationModified = "modified"
+	// migrationMissing is an applied migration without a local file.
+	migrationMissing = "missing"
+)
+
+// migration is a local migration file.
+type migration struct {
+	Version  int64
+	Name     string
+	Path     string
+	Checksum string
+}
+
+// migrationRecord is a row of the migrations ledger table.
+type migrationRecord struct {
+	Version   int64     `kusto:"Version"`
+	Name      string    `kusto:"Name"`
+	Checksum  string    `kusto:"Checksum"`
+	AppliedOn time.Time `kusto:"AppliedOn"`
+}
+
+// migrationStatus is the state of a migration, comparing the local file with the ledger.
+type migrationStatus struct {
+	Version   int64
+	Name      string
+	Status    string
+	AppliedOn time.Time
+	Migration migration
+}
+
+// loadMigrations reads the migration files of dir, ordered by version.
+// Files without the .kql extension are ignored.
+func loadMigrations(dir string) ([]migration, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read migrations directory %q: %w", dir, err)
+	}
+
+	var rv []migration
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".kql" {
+			continue
+		}
+
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %q, expected NNNN_description.kql", entry.Name())
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version %q: %w", entry.Name(), err)
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read migration %q: %w", path, err)
+		}
+		sum := sha256.Sum256(content)
+
+		rv = append(rv, migration{
+			Version:  version,
+			Name:     entry.Name(),
+			Path:     path,
+			Checksum: hex.EncodeToString(sum[:]),
+		})
+	}
+
+	slices.SortFunc(rv, func(a, b migration) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+	for i := 1; i < len(rv); i++ {
+		if rv[i].Version == rv[i-1].Version {
+			return nil, fmt.Errorf("duplicate migration version %d: %q and %q", rv[i].Version, rv[i-1].Name, rv[i].Name)
+		}
+	}
+
+	return rv, nil
+}
+
+// migrationStatuses compares the local migrations with the applied ones, ordered by version.
+func migrationStatuses(migrations []migration, applied []migrationRecord) []migrationStatus {
+	appliedByVersion := make(map[int64]migrationRecord, len(applied))
+	for _, record := range applied {
+		appliedByVersion[record.Version] = record
+	}
+
+	var rv []migrationStatus
+	for _, m := range migrations {
+		status := migrationStatus{Version: m.Version, Name: m.Name, Status: migrationPending, Migration: m}
+		if record, ok := appliedByVersion[m.Version]; ok {
+			status.AppliedOn = record.AppliedOn
+			status.Status = migrationApplied
+			if record.Checksum != m.Checksum {
+				status.Status = migrationModified
+			}
+			delete(appliedByVersion, m.Version)
+		}
+		rv = append(rv, status)
+	}
+	for _, record := range appliedByVersion {
+		rv = append(rv, migrationStatus{
+			Version:   record.Version,
+			Name:      record.Name,
+			Status:    migrationMissing,
+			AppliedOn: record.AppliedOn,
+		})
+	}
+
+	slices.SortFunc(rv, func(a, b migrationStatus) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+	return rv
+}
+
+// showAppliedMigrations reads the migrations ledger table, which doesn't exist
+// before the first migration.
+func showAppliedMigrations(
+	ctx context.Context,
+	queryClient ingest.QueryClient,
+	database string,
+	ledgerTable string,
+) ([]migrationRecord, error) {
+	exists, err := tableExists(ctx, queryClient, KustoTargetOptions{Database: database, Table: ledgerTable})
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, nil
+	}
+
+	stmt := kql.New("").
+		AddUnsafe(quoteIdentifier(ledgerTable)).
+		AddLiteral(" | project Version, Name, Checksum, AppliedOn | order by Version asc")
+	iter, err := queryClient.Query(ctx, database, stmt)
+	if err != nil {
+		return nil, err
+	}
+	if iter == nil {
+		return nil, nil
+	}
+	defer iter.Stop()
+
+	var rv []migrationRecord
+	err = iter.DoOnRowOrError(func(row *table.Row, inlineErr *kustoerrors.Error) error {
+		if inlineErr != nil {
+			return inlineErr
+		}
+
+		var rec migrationRecord
+		if err := row.ToStruct(&rec); err != nil {
+			return err
+		}
+		rv = append(rv, rec)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return rv, nil
+}
+
+// Validate checks the target options, the migrations don't use the target table.
+func (m MigrationsOptions) Validate() error {
+	return m.KustoTarget.validate(false)
+}
+
+func (m MigrationsOptions) logSettings(cli cli.Provider) {
+	cli.Logger().Debug(
+		"migrate settings",
+		"dir", m.Dir,
+		"ledgerTable", m.LedgerTable,
+		"target.endpoint", m.KustoTarget.Endpoint,
+		"target.database", m.KustoTarget.Database,
+		"auth.tenant", m.Auth.TenantID,
+		"auth.clientID", m.Auth.ClientID,
+		"maxRetries", m.MaxRetries,
+		"maxTimeout", m.MaxTimeout,
+	)
+}
+
+// migrationStatuses loads the local migrations and compares them with the ledger.
+func (m MigrationsOptions) migrationStatuses(
+	ctx context.Context,
+	cli cli.Provider,
+	queryClient ingest.QueryClient,
+) ([]migrationStatus, error) {
+	migrations, err := loadMigrations(m.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []migrationRecord
+	invokeShow := func() error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		var err error
+		applied, err = showAppliedMigrations(ctx, queryClient, m.KustoTarget.Database, m.LedgerTable)
+		return err
+	}
+	if err := invokeWithRetries(invokeShow, m.MaxRetries, m.MaxTimeout, cli.Logger()); err != nil {
+		return nil, fmt.Errorf("read migrations ledger %q: %w", m.LedgerTable, err)
+	}
+
+	return migrationStatuses(migrations, applied), nil
+}
+
+// loadStatements reads the commands of the migration.
+func (mig migration) loadStatements() ([]mgmtStatement, error) {
+	content, err := os.ReadFile(mig.Path)
+	if err != nil {
+		return nil, fmt.Errorf("read migration: %w", err)
+	}
+	statements, err := splitManagementScript(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("parse migration: %w", err)
+	}
+
+	return statements, nil
+}
+
+// applyMigration runs the commands of the migration and records it in the ledger.
+// The commands are not transactional, a failed migration may be partially applied.
+func (m MigrationsOptions) applyMigration(
+	ctx context.Context,
+	cli cli.Provider,
+	queryClient ingest.QueryClient,
+	mig migration,
+	statements []mgmtStatement,
+) error {
+	err := executeMgmtStatements(ctx, cli, queryClient, m.KustoTarget.Database, statements, m.MaxRetries, m.MaxTimeout, nil)
+	if err != nil {
+		return err
+	}
+
+	record := kql.New(".set-or-append ").
+		AddUnsafe(quoteIdentifier(m.LedgerTable)).
+		AddLiteral(" <| print Version=").AddLong(mig.Version).
+		AddLiteral(", Name=").AddString(mig.Name).
+		AddLiteral(", Checksum=").AddString(mig.Checksum).
+		AddLiteral(", AppliedOn=now()")
+	err = executeMgmt(ctx, cli, queryClient, m.KustoTarget.Database, record, m.MaxRetries, m.MaxTimeout)
+	if err != nil {
+		return fmt.Errorf("record in migrations ledger: %w", err)
+	}
+
+	return nil
+}
+
+func (m MigrateUpOptions) Run(cli cli.Provider) error {
+	m.logSettings(cli)
+	cli.Logger().Debug("migrate up settings", "allowDestructive", m.AllowDestructive, "allowOutOfOrder", m.AllowOutOfOrder)
+
+	queryClient, err := m.createQueryClient(m.KustoTarget, m.Auth)
+	if err != nil {
+		return fmt.Errorf("create Kusto query client: %w", err)
+	}
+	defer func() { _ = queryClient.Close() }()
+
+	ctx, cancel := cli.Context()
+	defer cancel()
+
+	statuses, err := m.migrationStatuses(ctx, cli, queryClient)
+	if err != nil {
+		return err
+	}
+
+	var (
+		pending    []migration
+		modified   []error
+		maxApplied int64
+	)
+	for _, status := range statuses {
+		switch status.Status {
+		case migrationPending:
+			pending = append(pending, status.Migration)
+		case migrationModified:
+			modified = append(modified, fmt.Errorf("migration %q was modified after it was applied", status.Name))
+		case migrationMissing:
+			cli.Logger().Warn("applied migration not found locally", "version", status.Version, "name", status.Name)
+		}
+		if status.Status != migrationPending {
+			maxApplied = max(maxApplied, status.Version)
+		}
+	}
+	if len(modified) > 0 {
+		return fmt.Errorf("checksum mismatch: %w", errors.Join(modified...))
+	}
+
+	// a pending migration older than an applied one was e.g. merged from another branch,
+	// it may depend on a state that the later migrations already changed
+	var outOfOrder []string
+	for _, mig := range pending {
+		if mig.Version < maxApplied {
+			outOfOrder = append(outOfOrder, mig.Name)
+		}
+	}
+	if len(outOfOrder) > 0 {
+		if !m.AllowOutOfOrder {
+			return fmt.Errorf(
+				"pending migrations older than the latest applied version %d: %s, use --allow-out-of-order to apply them",
+				maxApplied, strings.Join(outOfOrder, ", "),
+			)
+		}
+		cli.Logger().Warn("applying migrations out of order", "latestApplied", maxApplied, "migrations", outOfOrder)
+	}
+	if len(pending) == 0 {
+		cli.Logger().Info("no pending migrations", "applied", len(statuses))
+		return nil
+	}
+
+	// the destructive commands of all pending migrations are confirmed before applying any
+	statements := make([][]mgmtStatement, len(pending))
+	var destructive []string
+	for i, mig := range pending {
+		statements[i], err = mig.loadStatements()
+		if err != nil {
+			return fmt.Errorf("migration %q: %w", mig.Name, err)
+		}
+		for _, s := range statements[i] {
+			if classifyStatement(s.Text) == commandDestructive {
+				destructive = append(destructive, fmt.Sprintf("%s line %d: %s", mig.Name, s.Line, firstLine(s.Text)))
+			}
+		}
+	}
+	if err := confirmDestructiveCommands(cli, destructive, m.AllowDestructive); err != nil {
+		return err
+	}
+
+	createLedger := kql.New(".create-merge table ").
+		AddUnsafe(quoteIdentifier(m.LedgerTable)).
+		AddLiteral(" (Version: long, Name: string, Checksum: string, AppliedOn: datetime)")
+	err = executeMgmt(ctx, cli, queryClient, m.KustoTarget.Database, createLedger, m.MaxRetries, m.MaxTimeout)
+	if err != nil {
+		return fmt.Errorf("create migrations ledger %q: %w", m.LedgerTable, err)
+	}
+
+	start := time.Now()
+	for i, mig := range pending {
+		cli.Logger().Info("applying migration", "version", mig.Version, "name", mig.Name)
+
+		migrationStart := time.Now()
+		if err := m.applyMigration(ctx, cli, queryClient, mig, statements[i]); err != nil {
+			return fmt.Errorf("migration %q: %w", mig.Name, err)
+		}
+
+		cli.Logger().Info("migration applied", "version", mig.Version, "name", mig.Name, "duration", time.Since(migrationStart))
+	}
+
+	cli.Logger().Info("migrations applied successfully", "migrations", len(pending), "duration", time.Since(start))
+	return nil
+}
+
+func (m MigrateStatusOptions) Run(cli cli.Provider) error {
+	m.logSettings(cli)
+
+	queryClient, err := m.createQueryClient(m.KustoTarget, m.Auth)
+	if err != nil {
+		return fmt.Errorf("create Kusto query client: %w", err)
+	}
+	defer func() { _ = queryClient.Close() }()
+
+	ctx, cancel := cli.Context()
+	defer cancel()
+
+	statuses, err := m.migrationStatuses(ctx, cli, queryClient)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(cli.Stdout(), 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED ON")
+	for _, status := range statuses {
+		appliedOn := ""
+		if !status.AppliedOn.IsZero() {
+			appliedOn = status.AppliedOn.UTC().Format(time.RFC3339)
+		}
+		_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", status.Version, status.Name, status.Status, appliedOn)
+	}
+
+	return w.Flush()
+}
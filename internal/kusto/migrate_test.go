@@ -0,0 +1,303 @@
+package kusto
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/types"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/Azure/azure-kusto-go/kusto/ingest"
+	"github.com/Azure/kusto-ingest/internal/cli/testingcli"
+	"github.com/Azure/kusto-ingest/internal/kusto/testingkusto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestMigrations(t testing.TB, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	return dir
+}
+
+func testChecksum(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// newTestLedgerRows creates the migrations ledger query result.
+func newTestLedgerRows(t testing.TB, records ...migrationRecord) *kusto.RowIterator {
+	t.Helper()
+
+	mockRows, err := kusto.NewMockRows(table.Columns{
+		{Name: "Version", Type: types.Long},
+		{Name: "Name", Type: types.String},
+		{Name: "Checksum", Type: types.String},
+		{Name: "AppliedOn", Type: types.DateTime},
+	})
+	require.NoError(t, err)
+
+	for _, r := range records {
+		require.NoError(t, mockRows.Row(value.Values{
+			value.Long{Value: r.Version, Valid: true},
+			value.String{Value: r.Name, Valid: true},
+			value.String{Value: r.Checksum, Valid: true},
+			value.DateTime{Value: r.AppliedOn, Valid: true},
+		}))
+	}
+
+	iter := &kusto.RowIterator{}
+	require.NoError(t, iter.Mock(mockRows))
+	return iter
+}
+
+func Test_loadMigrations(t *testing.T) {
+	dir := writeTestMigrations(t, map[string]string{
+		"0010_add_policy.kql":  ".alter table Logs policy retention @'{}'",
+		"0002_create_logs.kql": ".create table Logs (msg: string)",
+		"README.md":            "not a migration",
+	})
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "0003_dir.kql"), 0o755))
+
+	migrations, err := loadMigrations(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []migration{
+		{
+			Version:  2,
+			Name:     "0002_create_logs.kql",
+			Path:     filepath.Join(dir, "0002_create_logs.kql"),
+			Checksum: testChecksum(".create table Logs (msg: string)"),
+		},
+		{
+			Version:  10,
+			Name:     "0010_add_policy.kql",
+			Path:     filepath.Join(dir, "0010_add_policy.kql"),
+			Checksum: testChecksum(".alter table Logs policy retention @'{}'"),
+		},
+	}, migrations)
+
+	t.Run("invalid name", func(t *testing.T) {
+		dir := writeTestMigrations(t, map[string]string{"create_logs.kql": ""})
+		_, err := loadMigrations(dir)
+		assert.ErrorContains(t, err, `invalid migration file name "create_logs.kql"`)
+	})
+
+	t.Run("duplicate version", func(t *testing.T) {
+		dir := writeTestMigrations(t, map[string]string{"0001_a.kql": "", "1_b.kql": ""})
+		_, err := loadMigrations(dir)
+		assert.ErrorContains(t, err, "duplicate migration version 1")
+	})
+}
+
+func Test_migrationStatuses(t *testing.T) {
+	appliedOn := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	migrations := []migration{
+		{Version: 1, Name: "0001_a.kql", Checksum: "a"},
+		{Version: 2, Name: "0002_b.kql", Checksum: "b"},
+		{Version: 4, Name: "0004_d.kql", Checksum: "d"},
+	}
+	applied := []migrationRecord{
+		{Version: 1, Name: "0001_a.kql", Checksum: "a", AppliedOn: appliedOn},
+		{Version: 2, Name: "0002_b.kql", Checksum: "changed", AppliedOn: appliedOn},
+		{Version: 3, Name: "0003_c.kql", Checksum: "c", AppliedOn: appliedOn},
+	}
+
+	var actual []string
+	for _, s := range migrationStatuses(migrations, applied) {
+		actual = append(actual, s.Name+" "+s.Status)
+	}
+	assert.Equal(t, []string{
+		"0001_a.kql applied",
+		"0002_b.kql modified",
+		"0003_c.kql missing",
+		"0004_d.kql pending",
+	}, actual)
+}
+
+func Test_MigrateUpOptions_Run(t *testing.T) {
+	files := map[string]string{
+		"0001_create_logs.kql": ".create table Logs (msg: string)\n",
+		"0002_alter_logs.kql":  ".alter table Logs docstring 'logs'\n\n.alter table Logs folder 'app'\n",
+	}
+	appliedOn := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	run := func(t *testing.T, ledger []migrationRecord, ledgerExists bool, configure ...func(*MigrateUpOptions)) ([]string, error) {
+		var executed []string
+		q := testingkusto.NewQueryClient(func(qc *testingkusto.QueryClient) {
+			qc.MgmtFn = func(_ context.Context, db string, stmt kusto.Statement, _ ...kusto.QueryOption) (*kusto.RowIterator, error) {
+				assert.Equal(t, "TestDatabase", db)
+				if stmt.String() == ".show tables" {
+					var tables [][]string
+					if ledgerExists {
+						tables = append(tables, []string{"SchemaMigrations"})
+					}
+					return newTestStringRows(t, []string{"TableName"}, tables...), nil
+				}
+				executed = append(executed, stmt.String())
+				return nil, nil
+			}
+			qc.QueryFn = func(_ context.Context, db string, stmt kusto.Statement, _ ...kusto.QueryOption) (*kusto.RowIterator, error) {
+				assert.Equal(t, "['SchemaMigrations'] | project Version, Name, Checksum, AppliedOn | order by Version asc", stmt.String())
+				return newTestLedgerRows(t, ledger...), nil
+			}
+		})
+
+		opts := MigrateUpOptions{
+			MigrationsOptions: MigrationsOptions{
+				Dir:         writeTestMigrations(t, files),
+				LedgerTable: "SchemaMigrations",
+				Auth:        newTestAuth(),
+				KustoTarget: KustoTargetOptions{Endpoint: "https://example.kusto.windows.net", Database: "TestDatabase"},
+				ingestorBuildSettings: ingestorBuildSettings{
+					CreateQueryClient: func(target KustoTargetOptions, auth AuthOptions) (ingest.QueryClient, error) {
+						return q, nil
+					},
+				},
+			},
+		}
+		for _, c := range configure {
+			c(&opts)
+		}
+		require.NoError(t, opts.Validate())
+		return executed, opts.Run(testingcli.New())
+	}
+
+	t.Run("first run", func(t *testing.T) {
+		executed, err := run(t, nil, false)
+		require.NoError(t, err)
+		require.Len(t, executed, 6)
+		assert.Equal(t, ".create-merge table ['SchemaMigrations'] (Version: long, Name: string, Checksum: string, AppliedOn: datetime)", executed[0])
+		assert.Equal(t, ".create table Logs (msg: string)", executed[1])
+		assert.True(t, strings.HasPrefix(executed[2], ".set-or-append ['SchemaMigrations'] <| print Version=long(1)"), executed[2])
+		assert.Contains(t, executed[2], testChecksum(files["0001_create_logs.kql"]))
+		assert.Equal(t, ".alter table Logs docstring 'logs'", executed[3])
+		assert.Equal(t, ".alter table Logs folder 'app'", executed[4])
+		assert.True(t, strings.HasPrefix(executed[5], ".set-or-append ['SchemaMigrations'] <| print Version=long(2)"), executed[5])
+	})
+
+	t.Run("pending only", func(t *testing.T) {
+		executed, err := run(t, []migrationRecord{
+			{Version: 1, Name: "0001_create_logs.kql", Checksum: testChecksum(files["0001_create_logs.kql"]), AppliedOn: appliedOn},
+		}, true)
+		require.NoError(t, err)
+		require.Len(t, executed, 4)
+		assert.Equal(t, ".alter table Logs docstring 'logs'", executed[1])
+	})
+
+	t.Run("up to date", func(t *testing.T) {
+		executed, err := run(t, []migrationRecord{
+			{Version: 1, Name: "0001_create_logs.kql", Checksum: testChecksum(files["0001_create_logs.kql"]), AppliedOn: appliedOn},
+			{Version: 2, Name: "0002_alter_logs.kql", Checksum: testChecksum(files["0002_alter_logs.kql"]), AppliedOn: appliedOn},
+		}, true)
+		require.NoError(t, err)
+		assert.Empty(t, executed)
+	})
+
+	t.Run("modified", func(t *testing.T) {
+		executed, err := run(t, []migrationRecord{
+			{Version: 1, Name: "0001_create_logs.kql", Checksum: "changed", AppliedOn: appliedOn},
+		}, true)
+		assert.ErrorContains(t, err, `migration "0001_create_logs.kql" was modified after it was applied`)
+		assert.Empty(t, executed, "should not apply pending migrations")
+	})
+
+	outOfOrderLedger := []migrationRecord{
+		{Version: 2, Name: "0002_alter_logs.kql", Checksum: testChecksum(files["0002_alter_logs.kql"]), AppliedOn: appliedOn},
+	}
+
+	t.Run("out of order refused", func(t *testing.T) {
+		executed, err := run(t, outOfOrderLedger, true)
+		assert.EqualError(t, err, "pending migrations older than the latest applied version 2: 0001_create_logs.kql, use --allow-out-of-order to apply them")
+		assert.Empty(t, executed)
+	})
+
+	t.Run("out of order allowed", func(t *testing.T) {
+		executed, err := run(t, outOfOrderLedger, true, func(opts *MigrateUpOptions) {
+			opts.AllowOutOfOrder = true
+		})
+		require.NoError(t, err)
+		require.Len(t, executed, 3)
+		assert.Equal(t, ".create table Logs (msg: string)", executed[1])
+	})
+
+	withDestructive := func(opts *MigrateUpOptions) {
+		opts.Dir = writeTestMigrations(t, map[string]string{
+			"0001_create_logs.kql": files["0001_create_logs.kql"],
+			"0002_drop_logs.kql":   ".drop table OldLogs\n",
+		})
+	}
+
+	t.Run("destructive refused", func(t *testing.T) {
+		executed, err := run(t, nil, false, withDestructive)
+		assert.ErrorContains(t, err, "refusing to run 1 destructive commands without --allow-destructive:\n0002_drop_logs.kql line 1: .drop table OldLogs")
+		assert.Empty(t, executed, "should not apply any migration")
+	})
+
+	t.Run("destructive allowed", func(t *testing.T) {
+		executed, err := run(t, nil, false, withDestructive, func(opts *MigrateUpOptions) {
+			opts.AllowDestructive = true
+		})
+		require.NoError(t, err)
+		require.Len(t, executed, 5)
+		assert.Equal(t, ".drop table OldLogs", executed[3])
+	})
+}
+
+func Test_MigrateStatusOptions_Run(t *testing.T) {
+	files := map[string]string{
+		"0001_create_logs.kql": ".create table Logs (msg: string)\n",
+		"0002_alter_logs.kql":  ".alter table Logs docstring 'logs'\n",
+	}
+	appliedOn := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	q := testingkusto.NewQueryClient(func(qc *testingkusto.QueryClient) {
+		qc.MgmtFn = func(_ context.Context, db string, stmt kusto.Statement, _ ...kusto.QueryOption) (*kusto.RowIterator, error) {
+			return newTestStringRows(t, []string{"TableName"}, []string{"SchemaMigrations"}), nil
+		}
+		qc.QueryFn = func(_ context.Context, db string, stmt kusto.Statement, _ ...kusto.QueryOption) (*kusto.RowIterator, error) {
+			return newTestLedgerRows(t, migrationRecord{
+				Version:   1,
+				Name:      "0001_create_logs.kql",
+				Checksum:  testChecksum(files["0001_create_logs.kql"]),
+				AppliedOn: appliedOn,
+			}), nil
+		}
+	})
+
+	stdout := &bytes.Buffer{}
+	cli := testingcli.New(func(tp *testingcli.TestProvider) {
+		tp.StdoutFn = func() io.Writer { return stdout }
+	})
+	opts := MigrateStatusOptions{
+		MigrationsOptions: MigrationsOptions{
+			Dir:         writeTestMigrations(t, files),
+			LedgerTable: "SchemaMigrations",
+			Auth:        newTestAuth(),
+			KustoTarget: newTestKustoTarget(),
+			ingestorBuildSettings: ingestorBuildSettings{
+				CreateQueryClient: func(target KustoTargetOptions, auth AuthOptions) (ingest.QueryClient, error) {
+					return q, nil
+				},
+			},
+		},
+	}
+	require.NoError(t, opts.Run(cli))
+	assert.Equal(t, `VERSION  NAME                  STATUS   APPLIED ON
+1        0001_create_logs.kql  applied  2024-01-01T00:00:00Z
+2        0002_alter_logs.kql   pending  
+`, stdout.String())
+	assert.Empty(t, q.MgmtCalls[1:], "status should not run management commands other than .show tables")
+}
@@ -0,0 +1,148 @@
+package kusto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/ingest"
+	"github.com/Azure/azure-kusto-go/kusto/kql"
+	"github.com/Azure/kusto-ingest/internal/cli"
+	"github.com/google/uuid"
+)
+
+const (
+	// operationPollBaseDelay is the delay before the first `.show operations` poll,
+	// doubled for each following poll up to operationPollMaxDelay.
+	operationPollBaseDelay = 1 * time.Second
+	operationPollMaxDelay  = 30 * time.Second
+
+	operationStateCompleted = "Completed"
+)
+
+// operationPendingStates are the states of operations that are not yet finished.
+// The other states (e.g. Failed, PartiallySucceeded, Abandoned, Canceled) are
+// terminal and only Completed is a success.
+// ref: https://learn.microsoft.com/en-us/kusto/management/show-operations
+var operationPendingStates = []string{"InProgress", "Scheduled", "Throttled", "Pending"}
+
+// operationRecord is a row of the `.show operations <id>` result.
+type operationRecord struct {
+	OperationId string
+	Operation   string
+	State       string
+	Status      string
+}
+
+// asyncOperationID returns the operation id of an async command result, i.e. a
+// single row with the OperationId column only.
+func asyncOperationID(result resultTable) (string, bool) {
+	if len(result.Columns) != 1 || result.Columns[0] != "OperationId" || len(result.Rows) != 1 {
+		return "", false
+	}
+
+	id, err := uuid.Parse(resultValueString(result.Rows[0][0]))
+	if err != nil {
+		return "", false
+	}
+	return id.String(), true
+}
+
+// operationRecordFromResult reads the operation record from the `.show operations` result.
+func operationRecordFromResult(result resultTable) (operationRecord, bool) {
+	var rv operationRecord
+	if len(result.Rows) == 0 {
+		return rv, false
+	}
+
+	// the last row is the latest state of the operation
+	row := result.Rows[len(result.Rows)-1]
+	for i, c := range result.Columns {
+		switch c {
+		case "OperationId":
+			rv.OperationId = resultValueString(row[i])
+		case "Operation":
+			rv.Operation = resultValueString(row[i])
+		case "State":
+			rv.State = resultValueString(row[i])
+		case "Status":
+			rv.Status = resultValueString(row[i])
+		}
+	}
+	return rv, true
+}
+
+// operationPollDelay returns the delay before the poll attempt.
+func (s ingestorBuildSettings) operationPollDelay(attempt int) time.Duration {
+	if s.PollOperationDelay != nil {
+		return s.PollOperationDelay(attempt)
+	}
+
+	return min(calculateDelay(attempt, operationPollBaseDelay), operationPollMaxDelay)
+}
+
+// waitForOperation polls `.show operations <id>` with backoff until the async
+// operation reaches a terminal state, bounded by timeout. It fails when the
+// operation didn't complete successfully.
+func (s ingestorBuildSettings) waitForOperation(
+	ctx context.Context,
+	cli cli.Provider,
+	queryer ingest.QueryClient,
+	database string,
+	operationID string,
+	timeout time.Duration,
+	maxRetries int,
+	maxTimeout int,
+) error {
+	logger := cli.Logger().With("operationId", operationID)
+	logger.Info("waiting for async operation", "timeout", timeout)
+
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// the poll fails with the context error when the timeout expires during the poll
+	waitErr := func(err error) error {
+		if errors.Is(waitCtx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("timed out waiting for async operation %s after %s", operationID, timeout)
+		}
+		if err == nil {
+			return waitCtx.Err()
+		}
+		return fmt.Errorf("show async operation %s: %w", operationID, err)
+	}
+
+	start := time.Now()
+	stmt := kql.New(".show operations ").AddUnsafe(operationID)
+	for attempt := 0; ; attempt++ {
+		select {
+		case <-waitCtx.Done():
+			return waitErr(nil)
+		case <-time.After(s.operationPollDelay(attempt)):
+		}
+
+		iter, err := executeMgmtResult(waitCtx, cli, queryer, database, stmt, maxRetries, maxTimeout)
+		if err != nil {
+			return waitErr(err)
+		}
+		result, err := readResultTable(iter)
+		if err != nil {
+			return waitErr(err)
+		}
+
+		op, ok := operationRecordFromResult(result)
+		if !ok || slices.Contains(operationPendingStates, op.State) {
+			logger.Debug("async operation in progress", "state", op.State, "elapsed", time.Since(start))
+			continue
+		}
+
+		if op.State != operationStateCompleted {
+			logger.Error("async operation failed", "operation", op.Operation, "state", op.State, "status", op.Status, "duration", time.Since(start))
+			return fmt.Errorf("async operation %s %s: %s", operationID, op.State, op.Status)
+		}
+
+		logger.Info("async operation completed", "operation", op.Operation, "state", op.State, "duration", time.Since(start))
+		return nil
+	}
+}
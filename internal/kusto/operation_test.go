@@ -0,0 +1,127 @@
+package kusto
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/Azure/kusto-ingest/internal/cli/testingcli"
+	"github.com/Azure/kusto-ingest/internal/kusto/testingkusto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testOperationID = "5b2c2a8e-0c4f-4d7c-9d55-2b5b0f6f1c11"
+
+// newTestOperationRows creates the `.show operations <id>` result of the state and status.
+func newTestOperationRows(t testing.TB, state string, status string) *kusto.RowIterator {
+	t.Helper()
+
+	return newTestStringRows(t,
+		[]string{"OperationId", "Operation", "State", "Status"},
+		[]string{testOperationID, "TableSetOrAppend", state, status},
+	)
+}
+
+func Test_asyncOperationID(t *testing.T) {
+	str := func(s string) value.Values { return value.Values{value.String{Value: s, Valid: true}} }
+
+	id, ok := asyncOperationID(resultTable{Columns: []string{"OperationId"}, Rows: []value.Values{str(testOperationID)}})
+	assert.True(t, ok)
+	assert.Equal(t, testOperationID, id)
+
+	for name, result := range map[string]resultTable{
+		"no rows":      {},
+		"other column": {Columns: []string{"TableName"}, Rows: []value.Values{str(testOperationID)}},
+		"not a guid":   {Columns: []string{"OperationId"}, Rows: []value.Values{str("1; .drop table T")}},
+		"many rows":    {Columns: []string{"OperationId"}, Rows: []value.Values{str(testOperationID), str(testOperationID)}},
+	} {
+		_, ok := asyncOperationID(result)
+		assert.False(t, ok, name)
+	}
+}
+
+func Test_waitForOperation(t *testing.T) {
+	run := func(t *testing.T, states ...[2]string) (int, error) {
+		polls := 0
+		q := testingkusto.NewQueryClient(func(qc *testingkusto.QueryClient) {
+			qc.MgmtFn = func(_ context.Context, db string, stmt kusto.Statement, _ ...kusto.QueryOption) (*kusto.RowIterator, error) {
+				assert.Equal(t, ".show operations "+testOperationID, stmt.String())
+				state := states[min(polls, len(states)-1)]
+				polls++
+				return newTestOperationRows(t, state[0], state[1]), nil
+			}
+		})
+
+		var delays []int
+		s := ingestorBuildSettings{
+			PollOperationDelay: func(attempt int) time.Duration {
+				delays = append(delays, attempt)
+				return time.Millisecond
+			},
+		}
+		err := s.waitForOperation(context.Background(), testingcli.New(), q, "TestDatabase", testOperationID, time.Minute, 0, 1)
+		assert.GreaterOrEqual(t, len(delays), polls, "should wait before each poll")
+		return polls, err
+	}
+
+	t.Run("completed", func(t *testing.T) {
+		polls, err := run(t, [2]string{"InProgress", ""}, [2]string{"Scheduled", ""}, [2]string{"Completed", ""})
+		require.NoError(t, err)
+		assert.Equal(t, 3, polls)
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		polls, err := run(t, [2]string{"InProgress", ""}, [2]string{"Failed", "Table 'Logs' was not found"})
+		assert.EqualError(t, err, "async operation "+testOperationID+" Failed: Table 'Logs' was not found")
+		assert.Equal(t, 2, polls)
+	})
+
+	t.Run("partially succeeded", func(t *testing.T) {
+		_, err := run(t, [2]string{"PartiallySucceeded", "some extents failed"})
+		assert.ErrorContains(t, err, "PartiallySucceeded: some extents failed")
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		// the poll blocks until the timeout expires
+		q := testingkusto.NewQueryClient(func(qc *testingkusto.QueryClient) {
+			qc.MgmtFn = func(ctx context.Context, db string, stmt kusto.Statement, _ ...kusto.QueryOption) (*kusto.RowIterator, error) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}
+		})
+		s := ingestorBuildSettings{
+			PollOperationDelay: func(int) time.Duration { return 0 },
+		}
+
+		err := s.waitForOperation(context.Background(), testingcli.New(), q, "TestDatabase", testOperationID, 10*time.Millisecond, 0, 1)
+		require.Error(t, err)
+		assert.True(t, strings.HasPrefix(err.Error(), "timed out waiting for async operation"), err.Error())
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		q := testingkusto.NewQueryClient(func(qc *testingkusto.QueryClient) {
+			qc.MgmtFn = func(ctx context.Context, db string, stmt kusto.Statement, _ ...kusto.QueryOption) (*kusto.RowIterator, error) {
+				cancel()
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}
+		})
+		s := ingestorBuildSettings{
+			PollOperationDelay: func(int) time.Duration { return 0 },
+		}
+
+		err := s.waitForOperation(ctx, testingcli.New(), q, "TestDatabase", testOperationID, time.Hour, 0, 1)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func Test_operationPollDelay(t *testing.T) {
+	s := ingestorBuildSettings{}
+	assert.GreaterOrEqual(t, s.operationPollDelay(0), operationPollBaseDelay)
+	assert.Equal(t, operationPollMaxDelay, s.operationPollDelay(10))
+}
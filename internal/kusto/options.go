@@ -1,5 +1,7 @@
 package kusto
 
+import "time"
+
 // AuthOptions provides the authenticate configuration for the Kusto client.
 // TODO: add support for MSI based authentication.
 type AuthOptions struct {
@@ -10,20 +12,37 @@ type AuthOptions struct {
 	AZCLI bool `env:"AZURE_CLI" help:"Use Azure CLI for authentication."`
 
 	ManagedIdentityResourceID string `env:"AZURE_MANAGED_IDENTITY_RESOURCE_ID" help:"The Azure managed identity resource ID."`
+
+	FederatedTokenFile string `env:"AZURE_FEDERATED_TOKEN_FILE" help:"The federated token file for workload identity authentication, used with the tenant and client ID."`
 }
 
 // KustoTargetOptions provides the target configuration for the Kusto client.
+// The fields are required, checked by Validate so that commands running offline
+// (e.g. file --dry-run) can skip them.
 type KustoTargetOptions struct {
-	Endpoint string `required:"" env:"KUSTO_ENDPOINT" help:"The Kusto endpoint to ingest data to."`
-	Database string `required:"" env:"KUSTO_DATABASE" help:"The Kusto database to ingest data to."`
-	Table    string `required:"" env:"KUSTO_TABLE" help:"The Kusto table to ingest data to."`
+	Endpoint string `env:"KUSTO_ENDPOINT" help:"The Kusto endpoint to ingest data to. Required."`
+	Database string `env:"KUSTO_DATABASE" help:"The Kusto database to ingest data to. Required."`
+	Table    string `env:"KUSTO_TABLE" help:"The Kusto table to ingest data to. Required."`
 }
 
 // FileIngestOptions provides the configuration for ingesting from local file.
 type FileIngestOptions struct {
-	SourceFile   string           `arg:"" type:"existingfile" required:"" help:"The source file to ingest."`
-	MappingsFile string           `optional:"" type:"existingfile" help:"The mappings file to use. Optional"`
-	Format       DataFormatString `optional:"" enum:"multijson,json,csv" default:"multijson" help:"The format of the source file. Default is multijson."`
+	SourceFiles   []string          `arg:"" required:"" help:"The source files, glob patterns or directories to ingest. Use \"-\" to read from stdin."`
+	MappingsFile  string            `optional:"" type:"existingfile" xor:"mapping" help:"The mappings file to use. Optional"`
+	MappingRef    string            `optional:"" xor:"mapping" help:"The name of a pre-created ingestion mapping on the table to use. Optional"`
+	CheckMapping  bool              `optional:"" help:"Check the --mapping-ref mapping exists on the table before ingestion."`
+	Format        DataFormatString  `optional:"" enum:"${data_formats}" default:"multijson" help:"The format of the source file, one of: ${enum}. Default is multijson."`
+	Compression   CompressionString `optional:"" enum:"${compressions}" default:"auto" help:"The compression of the source file, one of: ${enum}. Default is auto."`
+	MaxChunkBytes int64             `optional:"" help:"Split sources larger than this many (uncompressed) bytes into chunks along record boundaries. Default 0 disables chunking."`
+	Mode          IngestionMode     `optional:"" enum:"queued,streaming,managed" default:"queued" help:"The ingestion mode, one of: ${enum}. Default is queued."`
+	Recursive     bool              `optional:"" short:"r" help:"Include files in sub-directories when a source is a directory."`
+	Concurrency   int               `optional:"" default:"4" help:"Maximum number of files to ingest concurrently (default: 4)."`
+
+	CreateTableIfMissing bool `optional:"" help:"Create the table before ingestion when it doesn't exist, with the schema from --mappings-file or from sampling the first source file."`
+	DryRun               bool `optional:"" help:"Validate the source data against the format and mapping locally, without ingesting. No authentication or Kusto target needed."`
+
+	Properties IngestionPropertiesOptions `embed:"" group:"Ingestion properties"`
+	Transform  TransformOptions           `embed:"" group:"Transformations"`
 
 	Auth        AuthOptions        `embed:"" prefix:"auth-"`
 	KustoTarget KustoTargetOptions `embed:"" prefix:"kusto-"`
@@ -32,14 +51,98 @@ type FileIngestOptions struct {
 	MaxRetries int `optional:"" default:"3" help:"Maximum number of retries for transient errors (default: 3)."`
 	MaxTimeout int `optional:"" default:"60" help:"Maximum timeout in seconds for all retries (default: 60)."`
 
+	// Ingestion status configuration
+	Wait        bool `optional:"" help:"Wait for Kusto to report the final ingestion status, fail if the ingestion failed."`
+	WaitTimeout int  `optional:"" default:"600" help:"Maximum time in seconds to wait for the ingestion status (default: 600)."`
+
 	// for unit test
 	ingestorBuildSettings `kong:"-"`
 }
 
+// IngestionPropertiesOptions provides the Kusto ingestion properties for file ingestion.
+// ref: https://learn.microsoft.com/en-us/kusto/ingestion-properties
+type IngestionPropertiesOptions struct {
+	Tags                     []string  `optional:"" help:"Tags to associate with the ingested data."`
+	IngestByTags             []string  `optional:"" help:"Values of ingest-by: tags to associate with the ingested data."`
+	IngestIfNotExists        []string  `optional:"" help:"Skip the ingestion if the table already has data with any of these ingest-by: tag values."`
+	DropByTags               []string  `optional:"" help:"Values of drop-by: tags to associate with the ingested data."`
+	CreationTime             time.Time `optional:"" help:"Override the creation time (RFC3339) of the ingested data, used by the retention policy."`
+	FlushImmediately         bool      `optional:"" help:"Skip the batching policy and ingest the data immediately."`
+	IgnoreFirstRecord        bool      `optional:"" help:"The first record of csv-like sources is a header, ignore it. The header is kept in every chunk."`
+	ValidationPolicy         string    `optional:"" enum:"none,same-number-of-fields,ignore-non-double-quoted-fields" default:"none" help:"Validation policy for csv-like sources, one of: ${enum}. Default is none."`
+	ValidationIgnoreFailures bool      `optional:"" help:"Ignore validation policy failures instead of failing the ingestion."`
+	Dedupe                   bool      `optional:"" help:"Tag the data with the SHA-256 of the source (or of each chunk) as ingest-by: tag and skip the ingestion if the same data was ingested before."`
+}
+
+// TransformOptions provides the transformations applied to json and multijson records
+// while streaming them to Kusto.
+type TransformOptions struct {
+	AddColumn     []string `optional:"" sep:"none" placeholder:"NAME=VALUE" help:"Add a constant string column to every record, e.g. --add-column=environment=prod. Repeatable."`
+	Rename        []string `optional:"" placeholder:"OLD=NEW" help:"Rename a top-level field of every record. Repeatable."`
+	Drop          []string `optional:"" placeholder:"FIELD" help:"Drop a top-level field from every record. Repeatable."`
+	TransformSpec string   `optional:"" type:"existingfile" help:"JSON file with addColumns, rename and drop transformations, applied along with the flags."`
+}
+
 // ManagementOptions provides the configuration for management commands.
 type ManagementOptions struct {
 	Source []byte `arg:"" type:"filecontent" required:"" help:"The source file to execute."`
 
+	// Template configuration
+	Template   bool     `optional:"" help:"Render the source as a template, implied by --var and --vars-file."`
+	Vars       []string `optional:"" name:"var" sep:"none" placeholder:"KEY=VALUE" help:"Template variable for the source, used as {{ .KEY }}. Repeatable, overrides --vars-file."`
+	VarsFile   string   `optional:"" type:"existingfile" help:"File of KEY=VALUE template variable lines."`
+	RenderOnly bool     `optional:"" help:"Print the rendered source without executing it. No authentication or Kusto target needed."`
+
+	// Destructive commands guard
+	AllowDestructive bool `optional:"" help:"Allow destructive commands (e.g. .drop, .purge, .clear) without confirmation."`
+	Plan             bool `optional:"" help:"List the commands with their classification (read-only, additive, alter, destructive) without executing them. No authentication or Kusto target needed."`
+
+	Output string `optional:"" short:"o" enum:"${result_outputs}" default:"table" help:"The output format of the command results, one of: ${enum}. Default is table."`
+
+	// Async operations configuration
+	NoWait       bool `optional:"" help:"Don't wait for async operations (e.g. .set-or-append async) to complete, only print their OperationId."`
+	AsyncTimeout int  `optional:"" default:"3600" help:"Maximum time in seconds to wait for an async operation to complete (default: 3600)."`
+
+	Auth        AuthOptions        `embed:"" prefix:"auth-"`
+	KustoTarget KustoTargetOptions `embed:"" prefix:"kusto-"`
+
+	// Retry and timeout configuration
+	MaxRetries int `optional:"" default:"3" help:"Maximum number of retries for transient errors (default: 3)."`
+	MaxTimeout int `optional:"" default:"60" help:"Maximum timeout in seconds for all retries (default: 60)."`
+
+	// for unit test
+	ingestorBuildSettings `kong:"-"`
+}
+
+// QueryOptions provides the configuration for running KQL queries.
+type QueryOptions struct {
+	SourceFile string   `arg:"" optional:"" help:"The file with the query to run. Use \"-\" to read from stdin."`
+	Query      string   `optional:"" short:"q" help:"The query to run, instead of the source file."`
+	Params     []string `optional:"" name:"param" sep:"none" placeholder:"NAME:TYPE=VALUE" help:"Query parameter, declared with declare query_parameters. Types: ${query_parameter_types}. Repeatable."`
+	Output     string   `optional:"" short:"o" enum:"${result_outputs}" default:"table" help:"The output format of the query results, one of: ${enum}. Default is table."`
+
+	Auth        AuthOptions        `embed:"" prefix:"auth-"`
+	KustoTarget KustoTargetOptions `embed:"" prefix:"kusto-"`
+
+	// Retry and timeout configuration
+	MaxRetries int `optional:"" default:"3" help:"Maximum number of retries for transient errors (default: 3)."`
+	MaxTimeout int `optional:"" default:"60" help:"Maximum timeout in seconds for all retries (default: 60)."`
+
+	// for unit test
+	ingestorBuildSettings `kong:"-"`
+}
+
+// MigrateOptions provides the versioned schema migration commands.
+type MigrateOptions struct {
+	Up     MigrateUpOptions     `cmd:"" default:"withargs" help:"Apply the pending migrations (default)."`
+	Status MigrateStatusOptions `cmd:"" help:"List the applied and pending migrations."`
+}
+
+// MigrationsOptions provides the configuration shared by the migrate commands.
+type MigrationsOptions struct {
+	Dir         string `arg:"" type:"existingdir" help:"The directory of NNNN_description.kql migration files."`
+	LedgerTable string `optional:"" default:"SchemaMigrations" help:"The table recording the applied migrations (default: SchemaMigrations)."`
+
 	Auth        AuthOptions        `embed:"" prefix:"auth-"`
 	KustoTarget KustoTargetOptions `embed:"" prefix:"kusto-"`
 
@@ -50,3 +153,78 @@ type ManagementOptions struct {
 	// for unit test
 	ingestorBuildSettings `kong:"-"`
 }
+
+// MigrateUpOptions provides the configuration for applying the pending migrations.
+type MigrateUpOptions struct {
+	MigrationsOptions `embed:""`
+
+	AllowDestructive bool `optional:"" help:"Apply migrations with destructive commands (e.g. .drop, .purge) without confirmation."`
+	AllowOutOfOrder  bool `optional:"" help:"Apply pending migrations with a version lower than the latest applied one."`
+}
+
+// MigrateStatusOptions provides the configuration for listing the migrations.
+type MigrateStatusOptions struct {
+	MigrationsOptions `embed:""`
+}
+
+// ApplyOptions provides the configuration for applying a desired-state file.
+type ApplyOptions struct {
+	StateFile   string `arg:"" type:"existingfile" help:"The YAML or JSON file with the desired tables, columns, ingestion mappings and policies."`
+	Plan        bool   `optional:"" help:"Print the planned changes without applying them."`
+	AutoApprove bool   `optional:"" help:"Apply the planned changes without confirmation."`
+	// AllowDestructive is also required with AutoApprove for destructive changes
+	AllowDestructive bool `optional:"" help:"Apply destructive changes, e.g. column type changes, without confirmation."`
+
+	Auth        AuthOptions        `embed:"" prefix:"auth-"`
+	KustoTarget KustoTargetOptions `embed:"" prefix:"kusto-"`
+
+	// Retry and timeout configuration
+	MaxRetries int `optional:"" default:"3" help:"Maximum number of retries for transient errors (default: 3)."`
+	MaxTimeout int `optional:"" default:"60" help:"Maximum timeout in seconds for all retries (default: 60)."`
+
+	// for unit test
+	ingestorBuildSettings `kong:"-"`
+}
+
+// SchemaSampleOptions provides the sample source configuration for schema inference.
+type SchemaSampleOptions struct {
+	SourceFile  string            `arg:"" required:"" type:"existingfile" help:"The sample source file. Use \"-\" to read from stdin."`
+	Format      DataFormatString  `optional:"" enum:"auto,json,multijson,csv" default:"auto" help:"The format of the source file, one of: ${enum}. Default is auto."`
+	Compression CompressionString `optional:"" enum:"${compressions}" default:"auto" help:"The compression of the source file, one of: ${enum}. Default is auto."`
+	SampleSize  int               `optional:"" default:"100" help:"Maximum number of records to sample (default: 100)."`
+	Nested      string            `optional:"" enum:"dynamic,flatten" default:"dynamic" help:"How to map nested JSON objects, one of: ${enum}. Default is dynamic."`
+	Header      string            `optional:"" enum:"auto,present,absent" default:"auto" help:"Whether the first csv record is a header with the column names, one of: ${enum}. Default is auto."`
+}
+
+func (s SchemaSampleOptions) inferSchemaOptions() inferSchemaOptions {
+	return inferSchemaOptions{
+		SampleSize: s.SampleSize,
+		Nested:     s.Nested,
+		Header:     s.Header,
+	}
+}
+
+// MappingOptions groups the ingestion mapping commands.
+type MappingOptions struct {
+	Infer MappingInferOptions `cmd:"" help:"Infer an ingestion mapping from sample data."`
+}
+
+// MappingInferOptions provides the configuration for inferring an ingestion mapping.
+type MappingInferOptions struct {
+	Sample SchemaSampleOptions `embed:""`
+
+	Output string `optional:"" short:"o" type:"path" help:"Write the mapping to this file instead of stdout."`
+}
+
+// SchemaOptions groups the table schema commands.
+type SchemaOptions struct {
+	Infer SchemaInferOptions `cmd:"" help:"Infer a table schema from sample data and emit a .create-merge table command."`
+}
+
+// SchemaInferOptions provides the configuration for inferring a table schema.
+type SchemaInferOptions struct {
+	Sample SchemaSampleOptions `embed:""`
+
+	Table  string `required:"" env:"KUSTO_TABLE" help:"The name of the table to create."`
+	Output string `optional:"" short:"o" type:"path" help:"Write the command to this file instead of stdout, e.g. for the management command."`
+}
@@ -0,0 +1,286 @@
+package kusto
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto"
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+)
+
+const (
+	// ResultOutputsEnum is the enum of the result output formats.
+	ResultOutputsEnum = "table,json,ndjson,csv,none"
+
+	resultOutputTable  = "table"
+	resultOutputJSON   = "json"
+	resultOutputNDJSON = "ndjson"
+	resultOutputCSV    = "csv"
+	resultOutputNone   = "none"
+)
+
+// resultTable is the primary result table of a Kusto command.
+// The columns are only known when there are rows.
+type resultTable struct {
+	Columns []string
+	Rows    []value.Values
+}
+
+// readResultTable reads all rows of the iterator.
+func readResultTable(iter *kusto.RowIterator) (resultTable, error) {
+	var rv resultTable
+	if iter == nil {
+		return rv, nil
+	}
+	defer iter.Stop()
+
+	err := iter.DoOnRowOrError(func(row *table.Row, inlineErr *errors.Error) error {
+		if inlineErr != nil {
+			return inlineErr
+		}
+
+		if rv.Columns == nil {
+			rv.Columns = row.ColumnNames()
+		}
+		rv.Rows = append(rv.Rows, row.Values)
+		return nil
+	})
+	if err != nil {
+		return rv, fmt.Errorf("read result: %w", err)
+	}
+
+	return rv, nil
+}
+
+// resultValueString formats the value for text outputs, nulls are empty.
+func resultValueString(v value.Kusto) string {
+	switch v := v.(type) {
+	case value.Real:
+		if !v.Valid {
+			return ""
+		}
+		return strconv.FormatFloat(v.Value, 'g', -1, 64)
+	case value.DateTime:
+		if !v.Valid {
+			return ""
+		}
+		return v.Value.UTC().Format(time.RFC3339Nano)
+	default:
+		return v.String()
+	}
+}
+
+// resultValueJSON converts the value to its JSON value, nulls are null.
+func resultValueJSON(v value.Kusto) (json.RawMessage, error) {
+	var rv any
+	switch v := v.(type) {
+	case value.Bool:
+		rv = v.Value
+		if !v.Valid {
+			rv = nil
+		}
+	case value.Int:
+		rv = v.Value
+		if !v.Valid {
+			rv = nil
+		}
+	case value.Long:
+		rv = v.Value
+		if !v.Valid {
+			rv = nil
+		}
+	case value.Real:
+		rv = v.Value
+		if !v.Valid {
+			rv = nil
+		}
+	case value.Dynamic:
+		if !v.Valid {
+			return json.RawMessage("null"), nil
+		}
+		if json.Valid(v.Value) {
+			return json.RawMessage(v.Value), nil
+		}
+		rv = string(v.Value)
+	default:
+		rv = resultValueString(v)
+		if rv == "" {
+			// empty strings are valid, other types are null
+			if _, ok := v.(value.String); !ok {
+				rv = nil
+			}
+		}
+	}
+
+	return json.Marshal(rv)
+}
+
+// resultRowJSON writes the row as a JSON object, keeping the column order.
+func resultRowJSON(columns []string, row value.Values) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, v := range row {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(columns[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+
+		value, err := resultValueJSON(v)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", columns[i], err)
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// resultWriter writes result rows in the output format as they are read.
+type resultWriter struct {
+	w      io.Writer
+	output string
+	rows   int
+
+	tw *tabwriter.Writer
+	cw *csv.Writer
+	// cell replaces the tabs and line breaks that would break the table alignment
+	cell *strings.Replacer
+}
+
+func newResultWriter(w io.Writer, output string) (*resultWriter, error) {
+	rv := &resultWriter{w: w, output: output}
+	switch output {
+	case resultOutputNone, resultOutputJSON, resultOutputNDJSON:
+	case resultOutputTable:
+		rv.tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		rv.cell = strings.NewReplacer("\t", " ", "\r", " ", "\n", " ")
+	case resultOutputCSV:
+		rv.cw = csv.NewWriter(w)
+	default:
+		return nil, fmt.Errorf("unsupported output format %q", output)
+	}
+
+	return rv, nil
+}
+
+// WriteRow writes a row, the header is written with the first row.
+func (r *resultWriter) WriteRow(columns []string, row value.Values) error {
+	first := r.rows == 0
+	r.rows++
+
+	switch r.output {
+	case resultOutputTable:
+		if first {
+			_, _ = fmt.Fprintln(r.tw, strings.Join(columns, "\t"))
+		}
+		cells := make([]string, len(row))
+		for i, v := range row {
+			cells[i] = r.cell.Replace(resultValueString(v))
+		}
+		_, err := fmt.Fprintln(r.tw, strings.Join(cells, "\t"))
+		return err
+	case resultOutputCSV:
+		if first {
+			_ = r.cw.Write(columns)
+		}
+		cells := make([]string, len(row))
+		for i, v := range row {
+			cells[i] = resultValueString(v)
+		}
+		return r.cw.Write(cells)
+	case resultOutputJSON, resultOutputNDJSON:
+		object, err := resultRowJSON(columns, row)
+		if err != nil {
+			return err
+		}
+
+		prefix, suffix := "", "\n"
+		if r.output == resultOutputJSON {
+			prefix, suffix = ",\n  ", ""
+			if first {
+				prefix = "[\n  "
+			}
+		}
+		_, err = fmt.Fprintf(r.w, "%s%s%s", prefix, object, suffix)
+		return err
+	default:
+		return nil
+	}
+}
+
+// Close flushes the buffered rows and closes the JSON array.
+func (r *resultWriter) Close() error {
+	switch r.output {
+	case resultOutputTable:
+		return r.tw.Flush()
+	case resultOutputCSV:
+		r.cw.Flush()
+		return r.cw.Error()
+	case resultOutputJSON:
+		closing := "\n]\n"
+		if r.rows == 0 {
+			closing = "[]\n"
+		}
+		_, err := io.WriteString(r.w, closing)
+		return err
+	default:
+		return nil
+	}
+}
+
+// writeResultTable writes the result in the output format.
+func writeResultTable(w io.Writer, output string, result resultTable) error {
+	rw, err := newResultWriter(w, output)
+	if err != nil {
+		return err
+	}
+	for _, row := range result.Rows {
+		if err := rw.WriteRow(result.Columns, row); err != nil {
+			return err
+		}
+	}
+
+	return rw.Close()
+}
+
+// streamResultRows writes the rows of the iterator as they are read and
+// returns the number of rows.
+func streamResultRows(iter *kusto.RowIterator, w io.Writer, output string) (int, error) {
+	rw, err := newResultWriter(w, output)
+	if err != nil {
+		return 0, err
+	}
+
+	if iter != nil {
+		defer iter.Stop()
+
+		err = iter.DoOnRowOrError(func(row *table.Row, inlineErr *errors.Error) error {
+			if inlineErr != nil {
+				return inlineErr
+			}
+			return rw.WriteRow(row.ColumnNames(), row.Values)
+		})
+		if err != nil {
+			// flush the rows read so far
+			_ = rw.Close()
+			return rw.rows, fmt.Errorf("read result: %w", err)
+		}
+	}
+
+	return rw.rows, rw.Close()
+}
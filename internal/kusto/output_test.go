@@ -0,0 +1,98 @@
+package kusto
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/types"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestResultRows(t testing.TB) *kusto.RowIterator {
+	t.Helper()
+
+	mockRows, err := kusto.NewMockRows(table.Columns{
+		{Name: "Name", Type: types.String},
+		{Name: "Count", Type: types.Long},
+		{Name: "Ratio", Type: types.Real},
+		{Name: "Enabled", Type: types.Bool},
+		{Name: "Updated", Type: types.DateTime},
+		{Name: "Details", Type: types.Dynamic},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, mockRows.Row(value.Values{
+		value.String{Value: "Logs", Valid: true},
+		value.Long{Value: 42, Valid: true},
+		value.Real{Value: 0.5, Valid: true},
+		value.Bool{Value: true, Valid: true},
+		value.DateTime{Value: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Valid: true},
+		value.Dynamic{Value: []byte(`{"a": [1, 2]}`), Valid: true},
+	}))
+	require.NoError(t, mockRows.Row(value.Values{
+		value.String{Value: "with,comma", Valid: true},
+		value.Long{},
+		value.Real{},
+		value.Bool{},
+		value.DateTime{},
+		value.Dynamic{},
+	}))
+
+	iter := &kusto.RowIterator{}
+	require.NoError(t, iter.Mock(mockRows))
+	return iter
+}
+
+func Test_writeResultTable(t *testing.T) {
+	cases := map[string]string{
+		resultOutputTable: `Name        Count  Ratio  Enabled  Updated               Details
+Logs        42     0.5    true     2024-01-01T00:00:00Z  {"a": [1, 2]}
+with,comma                                               
+`,
+		resultOutputCSV: `Name,Count,Ratio,Enabled,Updated,Details
+Logs,42,0.5,true,2024-01-01T00:00:00Z,"{""a"": [1, 2]}"
+"with,comma",,,,,
+`,
+		resultOutputJSON: `[
+  {"Name":"Logs","Count":42,"Ratio":0.5,"Enabled":true,"Updated":"2024-01-01T00:00:00Z","Details":{"a": [1, 2]}},
+  {"Name":"with,comma","Count":null,"Ratio":null,"Enabled":null,"Updated":null,"Details":null}
+]
+`,
+		resultOutputNDJSON: `{"Name":"Logs","Count":42,"Ratio":0.5,"Enabled":true,"Updated":"2024-01-01T00:00:00Z","Details":{"a": [1, 2]}}
+{"Name":"with,comma","Count":null,"Ratio":null,"Enabled":null,"Updated":null,"Details":null}
+`,
+		resultOutputNone: "",
+	}
+
+	for output, expected := range cases {
+		t.Run(output, func(t *testing.T) {
+			result, err := readResultTable(newTestResultRows(t))
+			require.NoError(t, err)
+
+			buf := &bytes.Buffer{}
+			require.NoError(t, writeResultTable(buf, output, result))
+			assert.Equal(t, expected, buf.String())
+		})
+	}
+
+	t.Run("empty result", func(t *testing.T) {
+		result, err := readResultTable(nil)
+		require.NoError(t, err)
+
+		for output, expected := range map[string]string{
+			resultOutputTable:  "",
+			resultOutputCSV:    "",
+			resultOutputJSON:   "[]\n",
+			resultOutputNDJSON: "",
+		} {
+			buf := &bytes.Buffer{}
+			require.NoError(t, writeResultTable(buf, output, result))
+			assert.Equal(t, expected, buf.String(), output)
+		}
+	})
+}
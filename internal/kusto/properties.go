@@ -0,0 +1,167 @@
+package kusto
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-kusto-go/kusto/ingest"
+)
+
+const (
+	ingestByTagPrefix = "ingest-by:"
+	dropByTagPrefix   = "drop-by:"
+)
+
+var validationOptionsByString = map[string]ingest.ValidationOption{
+	"same-number-of-fields":           ingest.SameNumberOfFields,
+	"ignore-non-double-quoted-fields": ingest.IgnoreNonDoubleQuotedFields,
+}
+
+// AllTags returns the tags to associate with the ingested data, including the
+// ingest-by: and drop-by: tags.
+func (p IngestionPropertiesOptions) AllTags() []string {
+	var rv []string
+	rv = append(rv, p.Tags...)
+	for _, t := range p.IngestByTags {
+		rv = append(rv, ingestByTagPrefix+t)
+	}
+	for _, t := range p.DropByTags {
+		rv = append(rv, dropByTagPrefix+t)
+	}
+
+	return rv
+}
+
+func (p IngestionPropertiesOptions) hasValidationPolicy() bool {
+	return p.ValidationPolicy != "" && p.ValidationPolicy != "none"
+}
+
+// queuedOnlyFlags returns the flags of the set properties that only queued ingestion
+// applies. Streaming ingestion, also used by managed ingestion for small sources,
+// drops them.
+func (p IngestionPropertiesOptions) queuedOnlyFlags() []string {
+	var rv []string
+	if len(p.Tags) > 0 {
+		rv = append(rv, "--tags")
+	}
+	if len(p.IngestByTags) > 0 {
+		rv = append(rv, "--ingest-by-tags")
+	}
+	if len(p.IngestIfNotExists) > 0 {
+		rv = append(rv, "--ingest-if-not-exists")
+	}
+	if len(p.DropByTags) > 0 {
+		rv = append(rv, "--drop-by-tags")
+	}
+	if !p.CreationTime.IsZero() {
+		rv = append(rv, "--creation-time")
+	}
+	if p.IgnoreFirstRecord {
+		rv = append(rv, "--ignore-first-record")
+	}
+	if p.hasValidationPolicy() {
+		rv = append(rv, "--validation-policy")
+	}
+
+	return rv
+}
+
+// Validate checks the ingestion properties for conflicting combinations.
+// The format is used for checking csv-only properties, unless it is auto detected,
+// in which case they are checked again for the detected format of each source.
+func (p IngestionPropertiesOptions) Validate(format DataFormatString) error {
+	for _, tags := range [][]string{p.Tags, p.IngestByTags, p.IngestIfNotExists, p.DropByTags} {
+		for _, t := range tags {
+			if strings.TrimSpace(t) == "" {
+				return fmt.Errorf("tags cannot be empty")
+			}
+		}
+	}
+
+	for _, t := range p.Tags {
+		if strings.HasPrefix(t, ingestByTagPrefix) || strings.HasPrefix(t, dropByTagPrefix) {
+			return fmt.Errorf("tag %q: use --ingest-by-tags / --drop-by-tags for ingest-by: and drop-by: tags", t)
+		}
+	}
+
+	if p.hasValidationPolicy() {
+		if _, ok := validationOptionsByString[p.ValidationPolicy]; !ok {
+			return fmt.Errorf("unsupported validation policy: %q", p.ValidationPolicy)
+		}
+	} else if p.ValidationIgnoreFailures {
+		return fmt.Errorf("--validation-ignore-failures requires --validation-policy")
+	}
+
+	if format != DataFormatAuto && format.MappingKind() != ingest.CSV {
+		if p.IgnoreFirstRecord {
+			return fmt.Errorf("--ignore-first-record is only supported for csv-like formats, got %q", format)
+		}
+		if p.hasValidationPolicy() {
+			return fmt.Errorf("--validation-policy is only supported for csv-like formats, got %q", format)
+		}
+	}
+
+	return nil
+}
+
+// FileOptions returns the ingestion options for the properties.
+func (p IngestionPropertiesOptions) FileOptions() ([]ingest.FileOption, error) {
+	var rv []ingest.FileOption
+
+	if tags := p.AllTags(); len(tags) > 0 {
+		rv = append(rv, ingest.Tags(tags))
+	}
+
+	if len(p.IngestIfNotExists) > 0 {
+		// the property value is a JSON array of the ingest-by: tag values
+		b, err := json.Marshal(p.IngestIfNotExists)
+		if err != nil {
+			return nil, fmt.Errorf("encode ingest-if-not-exists tags: %w", err)
+		}
+		rv = append(rv, ingest.IfNotExists(string(b)))
+	}
+
+	if !p.CreationTime.IsZero() {
+		rv = append(rv, ingest.SetCreationTime(p.CreationTime))
+	}
+
+	if p.FlushImmediately {
+		rv = append(rv, ingest.FlushImmediately())
+	}
+
+	if p.IgnoreFirstRecord {
+		rv = append(rv, ingest.IgnoreFirstRecord())
+	}
+
+	if p.hasValidationPolicy() {
+		implications := ingest.FailIngestion
+		if p.ValidationIgnoreFailures {
+			implications = ingest.IgnoreFailures
+		}
+		rv = append(rv, ingest.ValidationPolicy(ingest.ValPolicy{
+			Options:      validationOptionsByString[p.ValidationPolicy],
+			Implications: implications,
+		}))
+	}
+
+	return rv, nil
+}
+
+// settings returns the effective properties as key/value pairs for logging.
+func (p IngestionPropertiesOptions) settings() []any {
+	rv := []any{
+		"properties.tags", p.AllTags(),
+		"properties.ingestIfNotExists", p.IngestIfNotExists,
+		"properties.flushImmediately", p.FlushImmediately,
+		"properties.ignoreFirstRecord", p.IgnoreFirstRecord,
+		"properties.validationPolicy", p.ValidationPolicy,
+		"properties.validationIgnoreFailures", p.ValidationIgnoreFailures,
+		"properties.dedupe", p.Dedupe,
+	}
+	if !p.CreationTime.IsZero() {
+		rv = append(rv, "properties.creationTime", p.CreationTime)
+	}
+
+	return rv
+}
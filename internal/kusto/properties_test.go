@@ -0,0 +1,151 @@
+package kusto
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_IngestionPropertiesOptions_Validate(t *testing.T) {
+	cases := []struct {
+		name        string
+		properties  IngestionPropertiesOptions
+		format      DataFormatString
+		errContains string
+	}{
+		{
+			name:   "empty",
+			format: "multijson",
+		},
+		{
+			name: "csv properties",
+			properties: IngestionPropertiesOptions{
+				IgnoreFirstRecord:        true,
+				ValidationPolicy:         "same-number-of-fields",
+				ValidationIgnoreFailures: true,
+			},
+			format: "csv",
+		},
+		{
+			name:       "csv properties with auto format",
+			properties: IngestionPropertiesOptions{IgnoreFirstRecord: true},
+			format:     DataFormatAuto,
+		},
+		{
+			name:        "ignore first record with json",
+			properties:  IngestionPropertiesOptions{IgnoreFirstRecord: true},
+			format:      "json",
+			errContains: "--ignore-first-record is only supported for csv-like formats",
+		},
+		{
+			name:        "validation policy with parquet",
+			properties:  IngestionPropertiesOptions{ValidationPolicy: "same-number-of-fields"},
+			format:      "parquet",
+			errContains: "--validation-policy is only supported for csv-like formats",
+		},
+		{
+			name:        "ignore failures without policy",
+			properties:  IngestionPropertiesOptions{ValidationPolicy: "none", ValidationIgnoreFailures: true},
+			format:      "csv",
+			errContains: "--validation-ignore-failures requires --validation-policy",
+		},
+		{
+			name:        "empty tag",
+			properties:  IngestionPropertiesOptions{IngestByTags: []string{" "}},
+			format:      "json",
+			errContains: "tags cannot be empty",
+		},
+		{
+			name:        "prefixed tag",
+			properties:  IngestionPropertiesOptions{Tags: []string{"drop-by:2024"}},
+			format:      "json",
+			errContains: "use --ingest-by-tags / --drop-by-tags",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.properties.Validate(c.format)
+			if c.errContains == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), c.errContains)
+		})
+	}
+}
+
+func Test_IngestionPropertiesOptions_FileOptions(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		options, err := IngestionPropertiesOptions{ValidationPolicy: "none"}.FileOptions()
+		require.NoError(t, err)
+		assert.Empty(t, options)
+	})
+
+	t.Run("all", func(t *testing.T) {
+		properties := IngestionPropertiesOptions{
+			Tags:              []string{"foo"},
+			IngestByTags:      []string{"batch-1"},
+			IngestIfNotExists: []string{"batch-1"},
+			DropByTags:        []string{"2024-01-01"},
+			CreationTime:      time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			FlushImmediately:  true,
+			IgnoreFirstRecord: true,
+			ValidationPolicy:  "ignore-non-double-quoted-fields",
+		}
+		assert.Equal(t, []string{"foo", "ingest-by:batch-1", "drop-by:2024-01-01"}, properties.AllTags())
+
+		options, err := properties.FileOptions()
+		require.NoError(t, err)
+
+		var names []string
+		for _, o := range options {
+			names = append(names, fmt.Sprint(o))
+		}
+		assert.Equal(t, []string{
+			"Tags",
+			"IfNotExists",
+			"SetCreationTime",
+			"FlushImmediately",
+			"IgnoreFirstRecord",
+			"ValidationPolicy",
+		}, names)
+	})
+}
+
+func Test_IngestionPropertiesOptions_StreamingMode(t *testing.T) {
+	options, err := IngestionPropertiesOptions{Tags: []string{"foo"}}.FileOptions()
+	require.NoError(t, err)
+
+	err = IngestionModeStreaming.checkFileOptions(options)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Tags is not supported in streaming ingestion mode")
+}
+
+func Test_FileIngestOptions_Validate_ManagedMode(t *testing.T) {
+	newOpts := func(mode IngestionMode, properties IngestionPropertiesOptions) FileIngestOptions {
+		return FileIngestOptions{
+			Format:      "csv",
+			Mode:        mode,
+			Properties:  properties,
+			KustoTarget: newTestKustoTarget(),
+		}
+	}
+
+	properties := IngestionPropertiesOptions{
+		Tags:              []string{"foo"},
+		IngestIfNotExists: []string{"batch-1"},
+		CreationTime:      time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		IgnoreFirstRecord: true,
+	}
+
+	err := newOpts(IngestionModeManaged, properties).Validate()
+	assert.EqualError(t, err, "--tags, --ingest-if-not-exists, --creation-time, --ignore-first-record: not supported in managed ingestion mode, streaming ingestion of small sources drops them, use --mode=queued")
+
+	assert.NoError(t, newOpts(IngestionModeQueued, properties).Validate())
+	assert.NoError(t, newOpts(IngestionModeManaged, IngestionPropertiesOptions{FlushImmediately: true}).Validate())
+}
@@ -0,0 +1,218 @@
+package kusto
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto"
+	"github.com/Azure/azure-kusto-go/kusto/kql"
+	"github.com/Azure/kusto-ingest/internal/cli"
+	"github.com/google/uuid"
+)
+
+// QueryParameterTypesEnum is the enum of the supported query parameter types.
+const QueryParameterTypesEnum = "string,bool,int,long,real,datetime,timespan,guid,dynamic"
+
+// queryParameterName matches the valid query parameter names.
+var queryParameterName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// parseQueryParameters parses the NAME:TYPE=VALUE query parameters.
+func parseQueryParameters(params []string) (*kql.Parameters, error) {
+	rv := kql.NewParameters()
+	for _, param := range params {
+		declaration, v, ok := strings.Cut(param, "=")
+		name, typ, hasType := strings.Cut(declaration, ":")
+		if !ok || !hasType {
+			return nil, fmt.Errorf("invalid --param %q, expected NAME:TYPE=VALUE", param)
+		}
+		if !queryParameterName.MatchString(name) {
+			return nil, fmt.Errorf("invalid --param %q: invalid name %q", param, name)
+		}
+
+		if err := addQueryParameter(rv, name, typ, v); err != nil {
+			return nil, fmt.Errorf("invalid --param %q: %w", param, err)
+		}
+	}
+
+	return rv, nil
+}
+
+func addQueryParameter(params *kql.Parameters, name string, typ string, v string) error {
+	switch typ {
+	case kustoTypeString:
+		params.AddString(name, v)
+	case kustoTypeBool:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return err
+		}
+		params.AddBool(name, b)
+	case kustoTypeInt:
+		n, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			return err
+		}
+		params.AddInt(name, int32(n))
+	case kustoTypeLong:
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return err
+		}
+		params.AddLong(name, n)
+	case kustoTypeReal:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return err
+		}
+		params.AddReal(name, f)
+	case kustoTypeDatetime:
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return err
+		}
+		params.AddDateTime(name, t)
+	case kustoTypeTimespan:
+		// Kusto timespans (e.g. 1d, 1.02:03:04), or Go durations (e.g. 1h30m)
+		d, err := parseKustoTimespan(v)
+		if err != nil {
+			var durationErr error
+			if d, durationErr = time.ParseDuration(v); durationErr != nil {
+				return err
+			}
+		}
+		params.AddTimespan(name, d)
+	case kustoTypeGUID:
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return err
+		}
+		params.AddGUID(name, id)
+	case kustoTypeDynamic:
+		var d any
+		if err := json.Unmarshal([]byte(v), &d); err != nil {
+			return err
+		}
+		params.AddDynamic(name, d)
+	default:
+		return fmt.Errorf("unsupported type %q, one of: %s", typ, QueryParameterTypesEnum)
+	}
+
+	return nil
+}
+
+func (q QueryOptions) Validate() error {
+	if q.SourceFile == "" && q.Query == "" {
+		return fmt.Errorf("either a source file or --query is required")
+	}
+	if q.SourceFile != "" && q.Query != "" {
+		return fmt.Errorf("a source file and --query can't be used together")
+	}
+	if _, err := parseQueryParameters(q.Params); err != nil {
+		return err
+	}
+
+	return q.KustoTarget.validate(false)
+}
+
+func (q QueryOptions) output() string {
+	if q.Output == "" {
+		return resultOutputTable
+	}
+
+	return q.Output
+}
+
+// readQuery reads the query from --query, the source file or stdin.
+func (q QueryOptions) readQuery(cli cli.Provider) (string, error) {
+	switch q.SourceFile {
+	case "":
+		return q.Query, nil
+	case stdinSourceFile:
+		content, err := io.ReadAll(cli.Stdin())
+		if err != nil {
+			return "", fmt.Errorf("read query from stdin: %w", err)
+		}
+		return string(content), nil
+	default:
+		content, err := os.ReadFile(q.SourceFile)
+		if err != nil {
+			return "", fmt.Errorf("read query file %q: %w", q.SourceFile, err)
+		}
+		return string(content), nil
+	}
+}
+
+func (q QueryOptions) Run(cli cli.Provider) error {
+	cli.Logger().Debug(
+		"query settings",
+		"sourceFile", q.SourceFile,
+		"params", q.Params,
+		"output", q.Output,
+		"target.endpoint", q.KustoTarget.Endpoint,
+		"target.database", q.KustoTarget.Database,
+		"auth.tenant", q.Auth.TenantID,
+		"auth.clientID", q.Auth.ClientID,
+		"maxRetries", q.MaxRetries,
+		"maxTimeout", q.MaxTimeout,
+	)
+
+	query, err := q.readQuery(cli)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(query) == "" {
+		return fmt.Errorf("the query is empty")
+	}
+	params, err := parseQueryParameters(q.Params)
+	if err != nil {
+		return err
+	}
+
+	queryClient, err := q.createQueryClient(q.KustoTarget, q.Auth)
+	if err != nil {
+		return fmt.Errorf("create Kusto query client: %w", err)
+	}
+	defer func() { _ = queryClient.Close() }()
+
+	ctx, cancel := cli.Context()
+	defer cancel()
+
+	var options []kusto.QueryOption
+	if params.Count() > 0 {
+		// the parameters are declared by the client with declare query_parameters
+		options = append(options, kusto.QueryParameters(params))
+	}
+
+	stmt := kql.New("").AddUnsafe(query)
+	var iter *kusto.RowIterator
+	invokeQuery := func() error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		var err error
+		iter, err = queryClient.Query(ctx, q.KustoTarget.Database, stmt, options...)
+		return err
+	}
+
+	cli.Logger().Info("executing query")
+	start := time.Now()
+	if err := invokeWithRetries(invokeQuery, q.MaxRetries, q.MaxTimeout, cli.Logger()); err != nil {
+		cli.Logger().Error("failed to execute query", "error", err)
+		return err
+	}
+
+	rows, err := streamResultRows(iter, cli.Stdout(), q.output())
+	if err != nil {
+		return err
+	}
+
+	cli.Logger().Info("query executed successfully", "rows", rows, "duration", time.Since(start))
+	return nil
+}
@@ -0,0 +1,150 @@
+package kusto
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto"
+	"github.com/Azure/azure-kusto-go/kusto/ingest"
+	"github.com/Azure/kusto-ingest/internal/cli/testingcli"
+	"github.com/Azure/kusto-ingest/internal/kusto/testingkusto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseQueryParameters(t *testing.T) {
+	cases := []struct {
+		param       string
+		declaration string
+		value       string
+	}{
+		{param: "name:string=a=b", declaration: "name:string", value: `"a=b"`},
+		{param: "enabled:bool=true", declaration: "enabled:bool", value: "bool(true)"},
+		{param: "n:int=42", declaration: "n:int", value: "int(42)"},
+		{param: "n:long=-42", declaration: "n:long", value: "long(-42)"},
+		{param: "ratio:real=0.5", declaration: "ratio:real", value: "real(0.5)"},
+		{param: "since:datetime=2024-01-01T00:00:00Z", declaration: "since:datetime", value: "datetime(2024-01-01T00:00:00Z)"},
+		{param: "window:timespan=1h30m", declaration: "window:timespan", value: "timespan(01:30:00.0000000)"},
+		{param: "window:timespan=1d", declaration: "window:timespan", value: "timespan(1.00:00:00.0000000)"},
+		{param: "window:timespan=1.02:03:04", declaration: "window:timespan", value: "timespan(1.02:03:04.0000000)"},
+		{param: "window:timespan=00:00:01.5", declaration: "window:timespan", value: "timespan(00:00:01.5000000)"},
+		{param: "id:guid=8f1e1a36-6d39-4b9c-9c1e-2c6a3f0e6c1d", declaration: "id:guid", value: "guid(8f1e1a36-6d39-4b9c-9c1e-2c6a3f0e6c1d)"},
+		{param: `tags:dynamic=["a","b"]`, declaration: "tags:dynamic", value: `dynamic(["a","b"])`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.param, func(t *testing.T) {
+			params, err := parseQueryParameters([]string{c.param})
+			require.NoError(t, err)
+			assert.Equal(t, "declare query_parameters("+c.declaration+");", params.ToDeclarationString())
+
+			name, _, _ := strings.Cut(c.declaration, ":")
+			assert.Equal(t, c.value, params.ToParameterCollection()[name])
+		})
+	}
+
+	for param, expected := range map[string]string{
+		"name=value":          "expected NAME:TYPE=VALUE",
+		"name:string":         "expected NAME:TYPE=VALUE",
+		"1name:string=value":  `invalid name "1name"`,
+		"n:long=abc":          `invalid --param "n:long=abc"`,
+		"n:decimal=1":         `unsupported type "decimal"`,
+		"since:datetime=2024": `invalid --param "since:datetime=2024"`,
+		"window:timespan=1w":  `invalid timespan "1w"`,
+	} {
+		_, err := parseQueryParameters([]string{param})
+		assert.ErrorContains(t, err, expected, param)
+	}
+}
+
+func Test_QueryOptions_Validate(t *testing.T) {
+	target := KustoTargetOptions{Endpoint: "https://example.kusto.windows.net", Database: "TestDatabase"}
+
+	assert.ErrorContains(t, QueryOptions{KustoTarget: target}.Validate(), "either a source file or --query is required")
+	assert.ErrorContains(t, QueryOptions{SourceFile: "-", Query: "T", KustoTarget: target}.Validate(), "can't be used together")
+	assert.ErrorContains(t, QueryOptions{Query: "T", Params: []string{"n"}, KustoTarget: target}.Validate(), "invalid --param")
+	assert.ErrorContains(t, QueryOptions{Query: "T"}.Validate(), "missing flags: --kusto-endpoint, --kusto-database")
+	assert.NoError(t, QueryOptions{Query: "T", KustoTarget: target}.Validate())
+}
+
+func Test_QueryOptions_Run(t *testing.T) {
+	run := func(t *testing.T, opts QueryOptions, stdin string, queryErr error) (string, []testingkusto.QueryCall, error) {
+		stdout := &bytes.Buffer{}
+		cli := testingcli.New(func(tp *testingcli.TestProvider) {
+			tp.StdoutFn = func() io.Writer { return stdout }
+			tp.StdinFn = func() io.Reader { return strings.NewReader(stdin) }
+		})
+
+		expectedOptions := 0
+		if len(opts.Params) > 0 {
+			expectedOptions = 1
+		}
+		q := testingkusto.NewQueryClient(func(qc *testingkusto.QueryClient) {
+			qc.QueryFn = func(_ context.Context, db string, stmt kusto.Statement, options ...kusto.QueryOption) (*kusto.RowIterator, error) {
+				assert.Len(t, options, expectedOptions, "parameters should be passed as a query option")
+				if queryErr != nil {
+					return nil, queryErr
+				}
+				return newTestStringRows(t, []string{"msg"}, []string{"hello"}, []string{"world"}), nil
+			}
+		})
+
+		opts.Auth = newTestAuth()
+		opts.KustoTarget = newTestKustoTarget()
+		opts.ingestorBuildSettings = ingestorBuildSettings{
+			CreateQueryClient: func(target KustoTargetOptions, auth AuthOptions) (ingest.QueryClient, error) {
+				return q, nil
+			},
+		}
+
+		err := opts.Run(cli)
+		return stdout.String(), q.QueryCalls, err
+	}
+
+	t.Run("query flag with parameters", func(t *testing.T) {
+		stdout, calls, err := run(t, QueryOptions{
+			Query:  "Logs | where Level == level | take n",
+			Params: []string{"level:string=error", "n:long=10"},
+			Output: resultOutputNDJSON,
+		}, "", nil)
+		require.NoError(t, err)
+		assert.Equal(t, "{\"msg\":\"hello\"}\n{\"msg\":\"world\"}\n", stdout)
+		require.Len(t, calls, 1)
+		assert.Equal(t, "TestDatabase", calls[0].DB)
+		assert.Equal(t, "Logs | where Level == level | take n", calls[0].Query.String())
+	})
+
+	t.Run("source file", func(t *testing.T) {
+		stdout, calls, err := run(t, QueryOptions{
+			SourceFile: writeToTestFile(t, "query.kql", []byte("Logs\n| take 2\n")),
+		}, "", nil)
+		require.NoError(t, err)
+		assert.Equal(t, "msg\nhello\nworld\n", stdout)
+		require.Len(t, calls, 1)
+		assert.Equal(t, "Logs\n| take 2\n", calls[0].Query.String())
+	})
+
+	t.Run("stdin", func(t *testing.T) {
+		stdout, calls, err := run(t, QueryOptions{SourceFile: "-", Output: resultOutputCSV}, "Logs | take 2", nil)
+		require.NoError(t, err)
+		assert.Equal(t, "msg\nhello\nworld\n", stdout)
+		require.Len(t, calls, 1)
+		assert.Equal(t, "Logs | take 2", calls[0].Query.String())
+	})
+
+	t.Run("empty query", func(t *testing.T) {
+		_, calls, err := run(t, QueryOptions{SourceFile: "-"}, "\n", nil)
+		assert.ErrorContains(t, err, "the query is empty")
+		assert.Empty(t, calls)
+	})
+
+	t.Run("query error", func(t *testing.T) {
+		_, calls, err := run(t, QueryOptions{Query: "Logs"}, "", errors.New("bad query"))
+		assert.ErrorContains(t, err, "bad query")
+		assert.Len(t, calls, 1, "non-retryable errors should not be retried")
+	})
+}
@@ -0,0 +1,117 @@
+package kusto
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Azure/kusto-ingest/internal/cli"
+)
+
+// kqlIdentifier returns the entity name as is when it's a plain identifier,
+// otherwise quoted as ['name'].
+func kqlIdentifier(name string) string {
+	if jsonPathIdentifier.MatchString(name) {
+		return name
+	}
+
+	return quoteIdentifier(name)
+}
+
+// tableColumn is a column of a table schema.
+type tableColumn struct {
+	Name string
+	Type string
+}
+
+func (s *inferredSchema) tableColumns() []tableColumn {
+	rv := make([]tableColumn, 0, len(s.Columns))
+	for _, c := range s.Columns {
+		rv = append(rv, tableColumn{Name: c.Name, Type: c.Type})
+	}
+
+	return rv
+}
+
+// buildTableCommand builds a table command with the column schema, e.g. `.create table`.
+func buildTableCommand(command string, table string, columns []tableColumn) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s %s (\n", command, kqlIdentifier(table))
+	for i, c := range columns {
+		fmt.Fprintf(&sb, "    %s: %s", kqlIdentifier(c.Name), c.Type)
+		if i < len(columns)-1 {
+			sb.WriteString(",")
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString(")\n")
+
+	return sb.String()
+}
+
+// buildCreateMergeTable builds the `.create-merge table` command of the inferred columns.
+// ref: https://learn.microsoft.com/en-us/kusto/management/create-merge-table-command
+func buildCreateMergeTable(table string, schema *inferredSchema) string {
+	return buildTableCommand(".create-merge table", table, schema.tableColumns())
+}
+
+// reportWidenedColumns logs the columns whose sampled values had mixed data types,
+// so the widened data types can be reviewed.
+func reportWidenedColumns(cli cli.Provider, schema *inferredSchema) []string {
+	var widened []string
+	for _, c := range schema.Columns {
+		if !c.Widened() {
+			continue
+		}
+
+		widened = append(widened, c.Name)
+		cli.Logger().Warn(
+			"column widened due to mixed data types",
+			"column", c.Name,
+			"observed", c.Observed,
+			"type", c.Type,
+		)
+	}
+
+	return widened
+}
+
+func (s SchemaInferOptions) Run(cli cli.Provider) error {
+	cli.Logger().Debug(
+		"schema infer settings",
+		"source", s.Sample.SourceFile,
+		"format", s.Sample.Format,
+		"compression", s.Sample.Compression,
+		"sampleSize", s.Sample.SampleSize,
+		"nested", s.Sample.Nested,
+		"header", s.Sample.Header,
+		"table", s.Table,
+		"output", s.Output,
+	)
+
+	schema, format, err := inferSchemaFromFile(
+		cli,
+		s.Sample.SourceFile,
+		s.Sample.Format,
+		s.Sample.Compression,
+		s.Sample.inferSchemaOptions(),
+	)
+	if err != nil {
+		return err
+	}
+
+	widened := reportWidenedColumns(cli, schema)
+
+	if err := writeOutput(cli, s.Output, []byte(buildCreateMergeTable(s.Table, schema))); err != nil {
+		return err
+	}
+
+	cli.Logger().Info(
+		"table schema inferred",
+		"table", s.Table,
+		"format", format,
+		"records", schema.Records,
+		"columns", len(schema.Columns),
+		"widened", widened,
+	)
+	return nil
+}
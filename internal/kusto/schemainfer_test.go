@@ -0,0 +1,110 @@
+package kusto
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto"
+	"github.com/Azure/azure-kusto-go/kusto/ingest"
+	"github.com/Azure/kusto-ingest/internal/cli/testingcli"
+	"github.com/Azure/kusto-ingest/internal/kusto/testingkusto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_buildCreateMergeTable(t *testing.T) {
+	schema, err := inferSchema(
+		strings.NewReader(`{"ts": "2024-01-01T00:00:00Z", "@level": "info", "n": 1}`),
+		"json",
+		inferSchemaOptions{},
+	)
+	require.NoError(t, err)
+
+	expected := `.create-merge table ['My Logs'] (
+    ts: datetime,
+    ['@level']: string,
+    n: long
+)
+`
+	assert.Equal(t, expected, buildCreateMergeTable("My Logs", schema))
+}
+
+func Test_reportWidenedColumns(t *testing.T) {
+	schema, err := inferSchema(
+		strings.NewReader(`{"a": 1, "b": 1, "c": 1} {"a": 1.5, "b": "x", "c": [1]}`),
+		"json",
+		inferSchemaOptions{},
+	)
+	require.NoError(t, err)
+
+	var types []string
+	for _, c := range schema.Columns {
+		types = append(types, c.Type)
+	}
+	assert.Equal(t, []string{kustoTypeReal, kustoTypeString, kustoTypeDynamic}, types)
+	assert.Equal(t, []string{"a", "b", "c"}, reportWidenedColumns(testingcli.New(), schema))
+}
+
+func Test_SchemaInferOptions_Run(t *testing.T) {
+	t.Run("to stdout", func(t *testing.T) {
+		stdout := &bytes.Buffer{}
+		cli := testingcli.New(func(tp *testingcli.TestProvider) {
+			tp.StdoutFn = func() io.Writer { return stdout }
+		})
+
+		opts := SchemaInferOptions{
+			Sample: SchemaSampleOptions{
+				SourceFile: testdataMultiJSON,
+				Format:     DataFormatAuto,
+				SampleSize: 100,
+			},
+			Table: "TestTable",
+		}
+		require.NoError(t, opts.Run(cli))
+		assert.Equal(t, ".create-merge table TestTable (\n    PreciseTimestamp: datetime,\n    msg: string\n)\n", stdout.String())
+	})
+
+	t.Run("to file for management command", func(t *testing.T) {
+		output := filepath.Join(t.TempDir(), "create-table.kql")
+
+		opts := SchemaInferOptions{
+			Sample: SchemaSampleOptions{
+				SourceFile: testdataMultiJSON,
+				Format:     DataFormatAuto,
+			},
+			Table:  "TestTable",
+			Output: output,
+		}
+		require.NoError(t, opts.Run(testingcli.New()))
+
+		source, err := os.ReadFile(output)
+		require.NoError(t, err)
+
+		var executed []string
+		q := testingkusto.NewQueryClient(func(qc *testingkusto.QueryClient) {
+			qc.MgmtFn = func(_ context.Context, _ string, stmt kusto.Statement, _ ...kusto.QueryOption) (*kusto.RowIterator, error) {
+				executed = append(executed, stmt.String())
+				return nil, nil
+			}
+		})
+		mgmt := ManagementOptions{
+			Source:      source,
+			Auth:        newTestAuth(),
+			KustoTarget: newTestKustoTarget(),
+			ingestorBuildSettings: ingestorBuildSettings{
+				CreateQueryClient: func(target KustoTargetOptions, auth AuthOptions) (ingest.QueryClient, error) {
+					return q, nil
+				},
+			},
+		}
+		require.NoError(t, mgmt.Run(testingcli.New()))
+		if assert.Len(t, executed, 1) {
+			assert.True(t, strings.HasPrefix(executed[0], ".create-merge table TestTable ("))
+		}
+	})
+}
@@ -0,0 +1,153 @@
+package kusto
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// mgmtStatement is a single control command of a management script.
+type mgmtStatement struct {
+	// Line is the 1-based line number where the command starts.
+	Line int
+	Text string
+}
+
+// scriptScanner tracks the literals and brackets that span multiple lines,
+// command boundaries inside them are ignored.
+type scriptScanner struct {
+	// quote is the quote of the open string literal, 0 when not in a string.
+	quote     byte
+	verbatim  bool
+	multiline bool
+	// literalLine is the line where the open string literal starts.
+	literalLine int
+	depth       int
+}
+
+func (s *scriptScanner) inLiteral() bool {
+	return s.quote != 0 || s.multiline
+}
+
+// scanLine scans a line of the script and returns its code, without the comment.
+func (s *scriptScanner) scanLine(line string, lineNo int) string {
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+
+		switch {
+		case s.multiline:
+			if strings.HasPrefix(line[i:], "```") {
+				s.multiline = false
+				i += 2
+			}
+		case s.quote != 0:
+			switch {
+			case !s.verbatim && c == '\\':
+				i++
+			case c == s.quote && s.verbatim && i+1 < len(line) && line[i+1] == s.quote:
+				// verbatim strings escape the quote by doubling it
+				i++
+			case c == s.quote:
+				s.quote = 0
+			}
+		case strings.HasPrefix(line[i:], "//"):
+			return strings.TrimRight(line[:i], " \t")
+		case strings.HasPrefix(line[i:], "```"):
+			s.multiline = true
+			s.literalLine = lineNo
+			i += 2
+		case c == '@' && i+1 < len(line) && (line[i+1] == '\'' || line[i+1] == '"'):
+			s.quote = line[i+1]
+			s.verbatim = true
+			s.literalLine = lineNo
+			i++
+		case c == '\'' || c == '"':
+			s.quote = c
+			s.verbatim = false
+			s.literalLine = lineNo
+		case c == '{' || c == '(' || c == '[':
+			s.depth++
+		case c == '}' || c == ')' || c == ']':
+			if s.depth > 0 {
+				s.depth--
+			}
+		}
+	}
+
+	return strings.TrimRight(line, " \t\r")
+}
+
+// isExecuteScript reports whether the command is a `.execute database script`,
+// which contains further control commands that must be sent as a whole.
+func isExecuteScript(command string) bool {
+	fields := strings.Fields(strings.ToLower(command))
+	return len(fields) > 0 && fields[0] == ".execute" && slices.Contains(fields, "script")
+}
+
+// splitManagementScript splits the script into its control commands.
+// A command starts at a line beginning with "." or after blank lines, unless
+// the line is inside a string literal, a multi-line string or brackets.
+// Comments are ignored for finding the commands, but the command text is sent as
+// written, e.g. keeping the comments of function bodies; only the comment lines
+// before and after a command are removed. A `.execute database script` command
+// takes the rest of the script.
+func splitManagementScript(script string) ([]mgmtStatement, error) {
+	var (
+		rv      []mgmtStatement
+		scanner scriptScanner
+		current strings.Builder
+		start   int
+		// end is the length of current up to the last line with code, which
+		// excludes the trailing comment lines
+		end int
+		// inScript is set for `.execute database script` commands
+		inScript bool
+	)
+
+	flush := func() {
+		text := strings.TrimSpace(current.String()[:end])
+		if text != "" {
+			rv = append(rv, mgmtStatement{Line: start, Text: text})
+		}
+		current.Reset()
+		start = 0
+		end = 0
+		inScript = false
+	}
+
+	for i, line := range strings.Split(script, "\n") {
+		lineNo := i + 1
+		atBoundary := !scanner.inLiteral() && scanner.depth == 0 && !inScript
+		if atBoundary {
+			trimmed := strings.TrimSpace(line)
+			if trimmed == "" {
+				flush()
+				continue
+			}
+			if strings.HasPrefix(trimmed, ".") {
+				flush()
+			}
+		}
+
+		code := scanner.scanLine(line, lineNo)
+		if start == 0 {
+			if strings.TrimSpace(code) == "" {
+				continue
+			}
+			start = lineNo
+			inScript = isExecuteScript(code)
+		}
+		current.WriteString(strings.TrimRight(line, " \t\r"))
+		current.WriteByte('\n')
+		if strings.TrimSpace(code) != "" || !atBoundary {
+			end = current.Len()
+		}
+	}
+
+	if scanner.inLiteral() {
+		return nil, fmt.Errorf("unterminated string literal at line %d", scanner.literalLine)
+	}
+	flush()
+
+	return rv, nil
+}
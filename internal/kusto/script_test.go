@@ -0,0 +1,118 @@
+package kusto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_splitManagementScript(t *testing.T) {
+	cases := []struct {
+		name     string
+		script   string
+		expected []mgmtStatement
+	}{
+		{
+			name:     "single command",
+			script:   ".show tables",
+			expected: []mgmtStatement{{Line: 1, Text: ".show tables"}},
+		},
+		{
+			name: "command per line",
+			script: `.create table TestTable (Timestamp: datetime, Message: string)
+.alter table TestTable policy update @'{"SoftDeletePeriod": "P365D"}'
+`,
+			expected: []mgmtStatement{
+				{Line: 1, Text: ".create table TestTable (Timestamp: datetime, Message: string)"},
+				{Line: 2, Text: `.alter table TestTable policy update @'{"SoftDeletePeriod": "P365D"}'`},
+			},
+		},
+		{
+			name: "multi-line commands and comments",
+			script: `// create the tables
+.create-merge table TestTable (
+    Timestamp: datetime, // event time
+    Message: string
+)
+
+.set-or-append TestTable <|
+    OtherTable
+    | where Message != "// not a comment"
+`,
+			expected: []mgmtStatement{
+				{Line: 2, Text: ".create-merge table TestTable (\n    Timestamp: datetime, // event time\n    Message: string\n)"},
+				{Line: 7, Text: ".set-or-append TestTable <|\n    OtherTable\n    | where Message != \"// not a comment\""},
+			},
+		},
+		{
+			name: "function body sent as written",
+			script: `.create-or-alter function with (docstring = "errors // only") F() {
+    // the errors of the last day
+    Logs
+    | where Level == "Error" // not warnings
+}
+// trailing comment
+.show functions
+`,
+			expected: []mgmtStatement{
+				{Line: 1, Text: ".create-or-alter function with (docstring = \"errors // only\") F() {\n    // the errors of the last day\n    Logs\n    | where Level == \"Error\" // not warnings\n}"},
+				{Line: 7, Text: ".show functions"},
+			},
+		},
+		{
+			name: "blank lines and command lines inside brackets",
+			script: `.create-or-alter function F() {
+    T
+
+    | take 1
+}
+`,
+			expected: []mgmtStatement{
+				{Line: 1, Text: ".create-or-alter function F() {\n    T\n\n    | take 1\n}"},
+			},
+		},
+		{
+			name: "blank lines and command lines inside strings",
+			script: ".alter table T policy update @'[{\n\n.x'' \"y\n}]'\n" +
+				".alter table T docstring ```\n\n.z\n```\n" +
+				".alter table T folder 'a\\'b'\n",
+			expected: []mgmtStatement{
+				{Line: 1, Text: ".alter table T policy update @'[{\n\n.x'' \"y\n}]'"},
+				{Line: 5, Text: ".alter table T docstring ```\n\n.z\n```"},
+				{Line: 9, Text: ".alter table T folder 'a\\'b'"},
+			},
+		},
+		{
+			name: "execute database script",
+			script: `.show tables
+.execute database script <|
+.create table A (x: int)
+
+.create table B (x: int)
+`,
+			expected: []mgmtStatement{
+				{Line: 1, Text: ".show tables"},
+				{Line: 2, Text: ".execute database script <|\n.create table A (x: int)\n\n.create table B (x: int)"},
+			},
+		},
+		{
+			name:     "only comments",
+			script:   "// nothing\n\n",
+			expected: nil,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			actual, err := splitManagementScript(c.script)
+			require.NoError(t, err)
+			assert.Equal(t, c.expected, actual)
+		})
+	}
+
+	t.Run("unterminated string", func(t *testing.T) {
+		_, err := splitManagementScript(".show tables\n.alter table T docstring 'abc\n")
+		assert.ErrorContains(t, err, "unterminated string literal at line 2")
+	})
+}
@@ -0,0 +1,101 @@
+package kusto
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// expandSourceFiles resolves the source arguments into a list of files to ingest.
+// Each argument can be:
+//
+// - a regular file
+// - a glob pattern (e.g. logs/*.multijson)
+// - a directory, whose files are included (recursively if requested)
+// - "-" for reading from stdin, which must be the only argument
+//
+// The returned list is de-duplicated and keeps the order of the arguments.
+func expandSourceFiles(args []string, recursive bool) ([]string, error) {
+	var rv []string
+	seen := map[string]bool{}
+	add := func(p string) {
+		if !seen[p] {
+			seen[p] = true
+			rv = append(rv, p)
+		}
+	}
+
+	for _, arg := range args {
+		if arg == stdinSourceFile {
+			if len(args) > 1 {
+				return nil, fmt.Errorf("%q cannot be combined with other source files", stdinSourceFile)
+			}
+			return []string{stdinSourceFile}, nil
+		}
+
+		var matches []string
+		if _, err := os.Stat(arg); err == nil {
+			matches = []string{arg}
+		} else {
+			globMatches, globErr := filepath.Glob(arg)
+			if globErr != nil {
+				return nil, fmt.Errorf("invalid glob pattern %q: %w", arg, globErr)
+			}
+			if len(globMatches) == 0 {
+				return nil, fmt.Errorf("source %q: %w", arg, err)
+			}
+			matches = globMatches
+		}
+
+		for _, m := range matches {
+			files, err := listSourceFiles(m, recursive)
+			if err != nil {
+				return nil, err
+			}
+			for _, f := range files {
+				add(f)
+			}
+		}
+	}
+
+	if len(rv) == 0 {
+		return nil, fmt.Errorf("no source files found")
+	}
+
+	return rv, nil
+}
+
+// listSourceFiles returns the regular files for the path. Directories are expanded
+// to the files in them, in lexical order.
+func listSourceFiles(p string, recursive bool) ([]string, error) {
+	stat, err := os.Stat(p)
+	if err != nil {
+		return nil, fmt.Errorf("source %q: %w", p, err)
+	}
+	if !stat.IsDir() {
+		return []string{p}, nil
+	}
+
+	var rv []string
+	err = filepath.WalkDir(p, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != p && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() {
+			rv = append(rv, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list directory %q: %w", p, err)
+	}
+
+	return rv, nil
+}
@@ -0,0 +1,63 @@
+package kusto
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_expandSourceFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.multijson", "b.multijson", "c.csv", "nested/d.multijson"} {
+		p := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0750))
+		require.NoError(t, os.WriteFile(p, []byte("{}"), 0640))
+	}
+	join := func(name string) string { return filepath.Join(dir, name) }
+
+	t.Run("files", func(t *testing.T) {
+		files, err := expandSourceFiles([]string{join("c.csv"), join("a.multijson"), join("c.csv")}, false)
+		require.NoError(t, err)
+		assert.Equal(t, []string{join("c.csv"), join("a.multijson")}, files)
+	})
+
+	t.Run("glob", func(t *testing.T) {
+		files, err := expandSourceFiles([]string{join("*.multijson")}, false)
+		require.NoError(t, err)
+		assert.Equal(t, []string{join("a.multijson"), join("b.multijson")}, files)
+	})
+
+	t.Run("directory", func(t *testing.T) {
+		files, err := expandSourceFiles([]string{dir}, false)
+		require.NoError(t, err)
+		assert.Equal(t, []string{join("a.multijson"), join("b.multijson"), join("c.csv")}, files)
+	})
+
+	t.Run("directory recursive", func(t *testing.T) {
+		files, err := expandSourceFiles([]string{dir}, true)
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			join("a.multijson"), join("b.multijson"), join("c.csv"), join("nested/d.multijson"),
+		}, files)
+	})
+
+	t.Run("stdin", func(t *testing.T) {
+		files, err := expandSourceFiles([]string{stdinSourceFile}, false)
+		require.NoError(t, err)
+		assert.Equal(t, []string{stdinSourceFile}, files)
+
+		_, err = expandSourceFiles([]string{stdinSourceFile, join("c.csv")}, false)
+		assert.Error(t, err)
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		_, err := expandSourceFiles([]string{join("missing.json")}, false)
+		assert.Error(t, err)
+
+		_, err = expandSourceFiles([]string{join("*.parquet")}, false)
+		assert.Error(t, err)
+	})
+}
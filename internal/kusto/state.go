@@ -0,0 +1,288 @@
+package kusto
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// desiredState is the desired state of the database for the apply command, e.g.
+//
+//	tables:
+//	  - name: Logs
+//	    columns:
+//	      - {name: Timestamp, type: datetime}
+//	      - {name: Message, type: string}
+//	    mappings:
+//	      - name: logs_json
+//	        kind: json
+//	        mapping:
+//	          - {Column: Timestamp, Properties: {Path: $.ts}}
+//	          - {Column: Message, Properties: {Path: $.msg}}
+//	    policies:
+//	      retention: {softDeletePeriod: 365d}
+//	      caching: {hot: 7d}
+type desiredState struct {
+	Tables []desiredTable `json:"tables"`
+}
+
+type desiredTable struct {
+	Name     string           `json:"name"`
+	Columns  []desiredColumn  `json:"columns"`
+	Mappings []desiredMapping `json:"mappings,omitempty"`
+	Policies desiredPolicies  `json:"policies"`
+}
+
+type desiredColumn struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+type desiredMapping struct {
+	Name    string                  `json:"name"`
+	Kind    string                  `json:"kind"`
+	Mapping []ingestionMappingEntry `json:"mapping"`
+}
+
+// desiredPolicies are the table policies, policies that are not set are not managed.
+type desiredPolicies struct {
+	Retention *retentionPolicySpec `json:"retention,omitempty"`
+	Caching   *cachingPolicySpec   `json:"caching,omitempty"`
+	// Update is managed when set, an empty list removes the update policies.
+	Update []updatePolicySpec `json:"update,omitempty"`
+}
+
+// retentionPolicySpec is the table retention policy.
+// ref: https://learn.microsoft.com/en-us/kusto/management/retention-policy
+type retentionPolicySpec struct {
+	SoftDeletePeriod string `json:"softDeletePeriod"`
+	// Recoverability is enabled or disabled, it's not managed when empty.
+	Recoverability string `json:"recoverability,omitempty"`
+}
+
+// cachingPolicySpec is the table caching policy.
+// ref: https://learn.microsoft.com/en-us/kusto/management/cache-policy
+type cachingPolicySpec struct {
+	Hot string `json:"hot"`
+}
+
+// updatePolicySpec is an update policy of the table, in the policy JSON format.
+// ref: https://learn.microsoft.com/en-us/kusto/management/update-policy
+type updatePolicySpec struct {
+	IsEnabled                    bool   `json:"IsEnabled"`
+	Source                       string `json:"Source"`
+	Query                        string `json:"Query"`
+	IsTransactional              bool   `json:"IsTransactional"`
+	PropagateIngestionProperties bool   `json:"PropagateIngestionProperties"`
+}
+
+// loadDesiredState reads the desired state from a YAML or JSON file.
+func loadDesiredState(path string) (desiredState, error) {
+	var rv desiredState
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return rv, fmt.Errorf("read state file %q: %w", path, err)
+	}
+
+	// JSON is YAML, the YAML is converted to JSON so that the mappings keep
+	// their JSON format and unknown fields are rejected the same way.
+	var doc any
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return rv, fmt.Errorf("parse state file %q: %w", path, err)
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return rv, fmt.Errorf("parse state file %q: %w", path, err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&rv); err != nil {
+		return rv, fmt.Errorf("parse state file %q: %w", path, err)
+	}
+
+	if err := rv.Validate(); err != nil {
+		return rv, fmt.Errorf("state file %q: %w", path, err)
+	}
+
+	return rv, nil
+}
+
+// supportedMappingKinds are the ingestion mapping kinds of the supported data formats.
+func supportedMappingKinds() []string {
+	var rv []string
+	for _, d := range supportedDataFormats {
+		kind := d.MappingKind.String()
+		if !slices.Contains(rv, kind) {
+			rv = append(rv, kind)
+		}
+	}
+
+	return rv
+}
+
+func (s desiredState) Validate() error {
+	tables := map[string]bool{}
+	for i, t := range s.Tables {
+		if t.Name == "" {
+			return fmt.Errorf("table %d has no name", i)
+		}
+		if tables[t.Name] {
+			return fmt.Errorf("duplicate table %q", t.Name)
+		}
+		tables[t.Name] = true
+
+		if err := t.Validate(); err != nil {
+			return fmt.Errorf("table %q: %w", t.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (t desiredTable) Validate() error {
+	if len(t.Columns) == 0 {
+		return fmt.Errorf("no columns")
+	}
+	columns := map[string]bool{}
+	for _, c := range t.Columns {
+		if c.Name == "" {
+			return fmt.Errorf("column without name")
+		}
+		if columns[c.Name] {
+			return fmt.Errorf("duplicate column %q", c.Name)
+		}
+		columns[c.Name] = true
+		if !isKnownKustoType(c.Type) {
+			return fmt.Errorf("column %q: unknown type %q", c.Name, c.Type)
+		}
+	}
+
+	for _, m := range t.Mappings {
+		if m.Name == "" {
+			return fmt.Errorf("mapping without name")
+		}
+		if !slices.Contains(supportedMappingKinds(), strings.ToLower(m.Kind)) {
+			return fmt.Errorf("mapping %q: unsupported kind %q, supported: %s", m.Name, m.Kind, strings.Join(supportedMappingKinds(), ", "))
+		}
+		if len(m.Mapping) == 0 {
+			return fmt.Errorf("mapping %q has no columns", m.Name)
+		}
+		for _, e := range m.Mapping {
+			if !columns[e.Column] {
+				return fmt.Errorf("mapping %q: column %q is not a table column", m.Name, e.Column)
+			}
+		}
+	}
+
+	if p := t.Policies.Retention; p != nil {
+		if _, err := parseKustoTimespan(p.SoftDeletePeriod); err != nil {
+			return fmt.Errorf("retention policy: softDeletePeriod: %w", err)
+		}
+		switch strings.ToLower(p.Recoverability) {
+		case "", "enabled", "disabled":
+		default:
+			return fmt.Errorf("retention policy: recoverability must be enabled or disabled, got %q", p.Recoverability)
+		}
+	}
+	if p := t.Policies.Caching; p != nil {
+		if _, err := parseKustoTimespan(p.Hot); err != nil {
+			return fmt.Errorf("caching policy: hot: %w", err)
+		}
+	}
+	for i, p := range t.Policies.Update {
+		if p.Source == "" || p.Query == "" {
+			return fmt.Errorf("update policy %d: Source and Query are required", i)
+		}
+	}
+
+	return nil
+}
+
+var (
+	// kustoTimespanLiteral matches timespan literals like 7d, 1.5h or 30m.
+	kustoTimespanLiteral = regexp.MustCompile(`^(\d+(?:\.\d+)?)(d|h|m|s|ms)$`)
+	// kustoTimespanClock matches the [d.]hh:mm:ss[.fffffff] timespan format.
+	kustoTimespanClock = regexp.MustCompile(`^(?:(\d+)\.)?(\d{1,2}):(\d{2}):(\d{2})(?:\.(\d{1,7}))?$`)
+)
+
+// parseKustoTimespan parses Kusto timespan literals (e.g. 365d) and the timespan
+// format used in policies (e.g. 365.00:00:00).
+// ref: https://learn.microsoft.com/en-us/kusto/query/scalar-data-types/timespan
+func parseKustoTimespan(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+
+	if m := kustoTimespanLiteral.FindStringSubmatch(s); m != nil {
+		n, err := strconv.ParseFloat(m[1], 64)
+		if err != nil {
+			return 0, err
+		}
+		unit := map[string]time.Duration{
+			"d":  24 * time.Hour,
+			"h":  time.Hour,
+			"m":  time.Minute,
+			"s":  time.Second,
+			"ms": time.Millisecond,
+		}[m[2]]
+		return time.Duration(n * float64(unit)), nil
+	}
+
+	if m := kustoTimespanClock.FindStringSubmatch(s); m != nil {
+		var parts [4]int64
+		for i, v := range m[1:5] {
+			if v == "" {
+				continue
+			}
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return 0, err
+			}
+			parts[i] = n
+		}
+		d := time.Duration(parts[0])*24*time.Hour +
+			time.Duration(parts[1])*time.Hour +
+			time.Duration(parts[2])*time.Minute +
+			time.Duration(parts[3])*time.Second
+		if m[5] != "" {
+			// fraction of seconds in 100ns ticks
+			ticks, err := strconv.ParseInt((m[5] + "000000")[:7], 10, 64)
+			if err != nil {
+				return 0, err
+			}
+			d += time.Duration(ticks) * 100
+		}
+		return d, nil
+	}
+
+	return 0, fmt.Errorf("invalid timespan %q", s)
+}
+
+// formatKustoTimespan formats the duration as a Kusto timespan literal in the
+// largest whole unit.
+func formatKustoTimespan(d time.Duration) string {
+	switch {
+	case d%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	case d%time.Second == 0:
+		return fmt.Sprintf("%ds", d/time.Second)
+	default:
+		return fmt.Sprintf("%dms", d/time.Millisecond)
+	}
+}
+
+// kqlVerbatimString quotes the value as a verbatim string literal.
+func kqlVerbatimString(s string) string {
+	return "@'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
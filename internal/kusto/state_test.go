@@ -0,0 +1,293 @@
+package kusto
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestStateFile(t testing.TB, name string, content string) string {
+	t.Helper()
+
+	p := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(p, []byte(content), 0o644))
+	return p
+}
+
+func Test_loadDesiredState(t *testing.T) {
+	expected := desiredState{
+		Tables: []desiredTable{
+			{
+				Name: "Logs",
+				Columns: []desiredColumn{
+					{Name: "Timestamp", Type: "datetime"},
+					{Name: "Message", Type: "string"},
+				},
+				Mappings: []desiredMapping{
+					{
+						Name: "logs_json",
+						Kind: "json",
+						Mapping: []ingestionMappingEntry{
+							{Column: "Timestamp", Properties: ingestionMappingProperties{Path: "$.ts"}},
+							{Column: "Message", Properties: ingestionMappingProperties{Path: "$.msg"}},
+						},
+					},
+				},
+				Policies: desiredPolicies{
+					Retention: &retentionPolicySpec{SoftDeletePeriod: "365d"},
+					Caching:   &cachingPolicySpec{Hot: "7d"},
+				},
+			},
+		},
+	}
+
+	t.Run("yaml", func(t *testing.T) {
+		p := writeTestStateFile(t, "state.yaml", `
+tables:
+  - name: Logs
+    columns:
+      - {name: Timestamp, type: datetime}
+      - {name: Message, type: string}
+    mappings:
+      - name: logs_json
+        kind: json
+        mapping:
+          - {Column: Timestamp, Properties: {Path: $.ts}}
+          - {Column: Message, Properties: {Path: $.msg}}
+    policies:
+      retention: {softDeletePeriod: 365d}
+      caching: {hot: 7d}
+`)
+		state, err := loadDesiredState(p)
+		require.NoError(t, err)
+		assert.Equal(t, expected, state)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		p := writeTestStateFile(t, "state.json", `{"tables": [{
+			"name": "Logs",
+			"columns": [{"name": "Timestamp", "type": "datetime"}, {"name": "Message", "type": "string"}],
+			"mappings": [{"name": "logs_json", "kind": "json", "mapping": [
+				{"Column": "Timestamp", "Properties": {"Path": "$.ts"}},
+				{"Column": "Message", "Properties": {"Path": "$.msg"}}
+			]}],
+			"policies": {"retention": {"softDeletePeriod": "365d"}, "caching": {"hot": "7d"}}
+		}]}`)
+		state, err := loadDesiredState(p)
+		require.NoError(t, err)
+		assert.Equal(t, expected, state)
+	})
+
+	for _, tc := range []struct {
+		name    string
+		content string
+		err     string
+	}{
+		{
+			name:    "unknown field",
+			content: "tables:\n  - name: Logs\n    colums: []\n",
+			err:     `unknown field "colums"`,
+		},
+		{
+			name:    "duplicate table",
+			content: "tables:\n  - {name: Logs, columns: [{name: a, type: string}]}\n  - {name: Logs, columns: [{name: a, type: string}]}\n",
+			err:     `duplicate table "Logs"`,
+		},
+		{
+			name:    "unknown type",
+			content: "tables:\n  - {name: Logs, columns: [{name: a, type: text}]}\n",
+			err:     `table "Logs": column "a": unknown type "text"`,
+		},
+		{
+			name: "mapping column",
+			content: "tables:\n  - name: Logs\n    columns: [{name: a, type: string}]\n" +
+				"    mappings: [{name: m, kind: json, mapping: [{Column: b, Properties: {Path: $.b}}]}]\n",
+			err: `mapping "m": column "b" is not a table column`,
+		},
+		{
+			name: "mapping kind",
+			content: "tables:\n  - name: Logs\n    columns: [{name: a, type: string}]\n" +
+				"    mappings: [{name: m, kind: xml, mapping: [{Column: a}]}]\n",
+			err: `mapping "m": unsupported kind "xml"`,
+		},
+		{
+			name:    "retention",
+			content: "tables:\n  - {name: Logs, columns: [{name: a, type: string}], policies: {retention: {softDeletePeriod: 1y}}}\n",
+			err:     `retention policy: softDeletePeriod: invalid timespan "1y"`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := loadDesiredState(writeTestStateFile(t, "state.yaml", tc.content))
+			assert.ErrorContains(t, err, tc.err)
+		})
+	}
+}
+
+func Test_parseKustoTimespan(t *testing.T) {
+	for input, expected := range map[string]time.Duration{
+		"365d":             365 * 24 * time.Hour,
+		"1.5h":             90 * time.Minute,
+		"30m":              30 * time.Minute,
+		"250ms":            250 * time.Millisecond,
+		"365.00:00:00":     365 * 24 * time.Hour,
+		"01:30:00":         90 * time.Minute,
+		"00:00:01.5000000": 1500 * time.Millisecond,
+	} {
+		actual, err := parseKustoTimespan(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, expected, actual, input)
+	}
+
+	_, err := parseKustoTimespan("7 days")
+	assert.Error(t, err)
+
+	assert.Equal(t, "365d", formatKustoTimespan(365*24*time.Hour))
+	assert.Equal(t, "36h", formatKustoTimespan(36*time.Hour))
+	assert.Equal(t, "90m", formatKustoTimespan(90*time.Minute))
+	assert.Equal(t, "1500ms", formatKustoTimespan(1500*time.Millisecond))
+}
+
+func Test_diffTable(t *testing.T) {
+	desired := desiredTable{
+		Name: "Logs",
+		Columns: []desiredColumn{
+			{Name: "Timestamp", Type: "datetime"},
+			{Name: "Level", Type: "string"},
+			{Name: "Count", Type: "long"},
+		},
+		Mappings: []desiredMapping{
+			{
+				Name: "logs_json",
+				Kind: "json",
+				Mapping: []ingestionMappingEntry{
+					{Column: "Timestamp", Properties: ingestionMappingProperties{Path: "$.ts"}},
+				},
+			},
+		},
+		Policies: desiredPolicies{
+			Retention: &retentionPolicySpec{SoftDeletePeriod: "365d", Recoverability: "disabled"},
+			Caching:   &cachingPolicySpec{Hot: "7d"},
+			Update: []updatePolicySpec{
+				{IsEnabled: true, Source: "RawLogs", Query: "ParseLogs()", IsTransactional: true},
+			},
+		},
+	}
+
+	t.Run("missing table", func(t *testing.T) {
+		plan, err := diffTable(desired, nil)
+		require.NoError(t, err)
+
+		var changes []string
+		for _, c := range plan.Changes {
+			changes = append(changes, c.String())
+		}
+		assert.Equal(t, []string{
+			"+ table Logs",
+			"+ column Logs.Timestamp: datetime",
+			"+ column Logs.Level: string",
+			"+ column Logs.Count: long",
+			"+ mapping Logs.logs_json (json)",
+			"~ retention policy Logs: softDeletePeriod 365d, recoverability disabled",
+			"~ caching policy Logs: hot 7d",
+			"~ update policy Logs: 1 policies",
+		}, changes)
+		assert.Equal(t, []string{
+			".create-merge table Logs (\n    Timestamp: datetime,\n    Level: string,\n    Count: long\n)\n",
+			`.create-or-alter table ['Logs'] ingestion json mapping @'logs_json' @'[{"Column":"Timestamp","DataType":"","Properties":{"Path":"$.ts"}}]'`,
+			".alter-merge table ['Logs'] policy retention softdelete = 365d recoverability = disabled",
+			".alter table ['Logs'] policy caching hot = 7d",
+			`.alter table ['Logs'] policy update @'[{"IsEnabled":true,"Source":"RawLogs","Query":"ParseLogs()","IsTransactional":true,"PropagateIngestionProperties":false}]'`,
+		}, plan.Commands)
+	})
+
+	t.Run("up to date", func(t *testing.T) {
+		current := &currentTable{
+			Columns: []tableColumn{
+				{Name: "Timestamp", Type: "datetime"},
+				{Name: "Level", Type: "string"},
+				{Name: "Count", Type: "long"},
+			},
+			Mappings: []ingestionMappingRecord{
+				{Name: "logs_json", Kind: "Json", Mapping: `[{"column":"Timestamp","Path":"$.ts","transform":null}]`},
+			},
+			Retention: &currentRetentionPolicy{SoftDeletePeriod: "365.00:00:00", Recoverability: "Disabled"},
+			Caching:   &currentCachingPolicy{DataHotSpan: "7.00:00:00"},
+			Update:    desired.Policies.Update,
+		}
+
+		plan, err := diffTable(desired, current)
+		require.NoError(t, err)
+		assert.Empty(t, plan.Changes)
+		assert.Empty(t, plan.Commands)
+	})
+
+	t.Run("changed", func(t *testing.T) {
+		current := &currentTable{
+			Columns: []tableColumn{
+				{Name: "Timestamp", Type: "datetime"},
+				{Name: "Level", Type: "int"},
+				{Name: "Host", Type: "string"},
+			},
+			Mappings: []ingestionMappingRecord{
+				{Name: "logs_json", Kind: "Json", Mapping: `[{"Column":"Timestamp","Properties":{"Path":"$.time"}}]`},
+				{Name: "old_csv", Kind: "Csv", Mapping: `[]`},
+			},
+			Retention: &currentRetentionPolicy{SoftDeletePeriod: "30.00:00:00", Recoverability: "Disabled"},
+			Caching:   &currentCachingPolicy{DataHotSpan: "7.00:00:00"},
+		}
+
+		plan, err := diffTable(desired, current)
+		require.NoError(t, err)
+
+		var changes []string
+		for _, c := range plan.Changes {
+			changes = append(changes, c.String())
+		}
+		assert.Equal(t, []string{
+			"~ column Logs.Level: int -> string (destructive)",
+			"+ column Logs.Count: long",
+			"! column Logs.Host: not in the desired state, not dropped",
+			"~ mapping Logs.logs_json (json)",
+			"! mapping Logs.old_csv (csv): not in the desired state, not dropped",
+			"~ retention policy Logs: softDeletePeriod 30.00:00:00 -> 365d",
+			"~ update policy Logs: 1 policies",
+		}, changes)
+		require.Len(t, plan.Commands, 5)
+		assert.Equal(t, ".alter column ['Logs'].['Level'] type=string", plan.Commands[0])
+		assert.Equal(t, ".create-merge table Logs (\n    Timestamp: datetime,\n    Level: string,\n    Count: long\n)\n", plan.Commands[1])
+		assert.Equal(t, commandDestructive, classifyStatement(plan.Commands[0]), "type change should be guarded")
+	})
+
+	t.Run("type aliases", func(t *testing.T) {
+		aliases := desiredTable{
+			Name: "Metrics",
+			Columns: []desiredColumn{
+				{Name: "Value", Type: "double"},
+				{Name: "Enabled", Type: "boolean"},
+				{Name: "Day", Type: "date"},
+				{Name: "Id", Type: "uniqueid"},
+				{Name: "Duration", Type: "time"},
+				{Name: "Name", Type: "String"},
+			},
+		}
+		current := &currentTable{
+			Columns: []tableColumn{
+				{Name: "Value", Type: "real"},
+				{Name: "Enabled", Type: "bool"},
+				{Name: "Day", Type: "datetime"},
+				{Name: "Id", Type: "guid"},
+				{Name: "Duration", Type: "timespan"},
+				{Name: "Name", Type: "string"},
+			},
+		}
+
+		plan, err := diffTable(aliases, current)
+		require.NoError(t, err)
+		assert.Empty(t, plan.Changes)
+		assert.Empty(t, plan.Commands)
+	})
+}
@@ -0,0 +1,388 @@
+package kusto
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/ingest"
+	"github.com/Azure/azure-kusto-go/kusto/kql"
+)
+
+const (
+	stateChangeCreate = "+"
+	stateChangeUpdate = "~"
+	// stateChangeIgnore is a difference that is not applied, e.g. an extra column.
+	stateChangeIgnore = "!"
+)
+
+// stateChange is a difference between the current and the desired state.
+type stateChange struct {
+	Action  string
+	Subject string
+	Detail  string
+}
+
+func (c stateChange) String() string {
+	if c.Detail == "" {
+		return c.Action + " " + c.Subject
+	}
+
+	return c.Action + " " + c.Subject + ": " + c.Detail
+}
+
+// statePlan is the changes and the management commands applying them.
+type statePlan struct {
+	Changes  []stateChange
+	Commands []string
+}
+
+func (p *statePlan) add(command string, changes ...stateChange) {
+	p.Changes = append(p.Changes, changes...)
+	if command != "" {
+		p.Commands = append(p.Commands, command)
+	}
+}
+
+// currentTable is the current state of a table.
+type currentTable struct {
+	Columns   []tableColumn
+	Mappings  []ingestionMappingRecord
+	Retention *currentRetentionPolicy
+	Caching   *currentCachingPolicy
+	Update    []updatePolicySpec
+}
+
+// currentRetentionPolicy is the retention policy JSON of `.show table T policy retention`.
+type currentRetentionPolicy struct {
+	SoftDeletePeriod string
+	Recoverability   string
+}
+
+// currentCachingPolicy is the caching policy JSON of `.show table T policy caching`.
+type currentCachingPolicy struct {
+	DataHotSpan cachingHotSpan
+}
+
+// cachingHotSpan is the hot span, either as timespan or as {"Value": timespan}.
+type cachingHotSpan string
+
+func (s *cachingHotSpan) UnmarshalJSON(b []byte) error {
+	var v struct{ Value string }
+	if err := json.Unmarshal(b, &v); err == nil {
+		*s = cachingHotSpan(v.Value)
+		return nil
+	}
+
+	var str string
+	if err := json.Unmarshal(b, &str); err != nil {
+		return err
+	}
+	*s = cachingHotSpan(str)
+	return nil
+}
+
+// showFirstString runs the management command and returns the string column of the
+// first row, empty when there are no rows.
+func showFirstString(ctx context.Context, queryClient ingest.QueryClient, database string, stmt *kql.Builder, column string) (string, error) {
+	iter, err := queryClient.Mgmt(ctx, database, stmt)
+	if err != nil {
+		return "", err
+	}
+	if iter == nil {
+		return "", nil
+	}
+	defer iter.Stop()
+
+	var rv string
+	found := false
+	err = iter.DoOnRowOrError(func(row *table.Row, inlineErr *errors.Error) error {
+		if inlineErr != nil {
+			return inlineErr
+		}
+		if found {
+			return nil
+		}
+
+		for i, c := range row.ColumnTypes {
+			if c.Name == column {
+				rv = row.Values[i].String()
+				found = true
+				return nil
+			}
+		}
+		return fmt.Errorf("no %s column in the result", column)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return rv, nil
+}
+
+// decodePolicy decodes the Policy JSON, "null" or empty when not set on the table.
+func decodePolicy(policy string, v any) (bool, error) {
+	if policy == "" || policy == "null" {
+		return false, nil
+	}
+	if err := json.Unmarshal([]byte(policy), v); err != nil {
+		return false, fmt.Errorf("parse policy %s: %w", policy, err)
+	}
+
+	return true, nil
+}
+
+// showCurrentTable reads the current state of the table.
+func showCurrentTable(ctx context.Context, queryClient ingest.QueryClient, database string, name string) (currentTable, error) {
+	var rv currentTable
+
+	schema, err := showFirstString(ctx, queryClient, database,
+		kql.New(".show table ").AddUnsafe(quoteIdentifier(name)).AddLiteral(" schema as json"),
+		"Schema",
+	)
+	if err != nil {
+		return rv, fmt.Errorf("show schema: %w", err)
+	}
+	var tableSchema struct {
+		OrderedColumns []struct {
+			Name    string
+			CslType string
+		}
+	}
+	if err := json.Unmarshal([]byte(schema), &tableSchema); err != nil {
+		return rv, fmt.Errorf("parse schema %s: %w", schema, err)
+	}
+	for _, c := range tableSchema.OrderedColumns {
+		rv.Columns = append(rv.Columns, tableColumn{Name: c.Name, Type: c.CslType})
+	}
+
+	rv.Mappings, err = showTableIngestionMappings(ctx, queryClient, KustoTargetOptions{Database: database, Table: name})
+	if err != nil {
+		return rv, fmt.Errorf("show ingestion mappings: %w", err)
+	}
+
+	showPolicy := func(kind string, v any) (bool, error) {
+		policy, err := showFirstString(ctx, queryClient, database,
+			kql.New(".show table ").AddUnsafe(quoteIdentifier(name)).AddLiteral(" policy ").AddUnsafe(kind),
+			"Policy",
+		)
+		if err != nil {
+			return false, fmt.Errorf("show %s policy: %w", kind, err)
+		}
+		return decodePolicy(policy, v)
+	}
+
+	var retention currentRetentionPolicy
+	if ok, err := showPolicy("retention", &retention); err != nil {
+		return rv, err
+	} else if ok {
+		rv.Retention = &retention
+	}
+	var caching currentCachingPolicy
+	if ok, err := showPolicy("caching", &caching); err != nil {
+		return rv, err
+	} else if ok {
+		rv.Caching = &caching
+	}
+	if _, err := showPolicy("update", &rv.Update); err != nil {
+		return rv, err
+	}
+
+	return rv, nil
+}
+
+// normalizeMapping folds the legacy top-level Path / Ordinal format of the
+// `.show ingestion mappings` result into the Properties format.
+func normalizeMapping(mapping string) ([]ingestionMappingEntry, error) {
+	var entries []struct {
+		ingestionMappingEntry
+		Path      string
+		Ordinal   mappingOrdinal
+		Transform string
+	}
+	if err := json.Unmarshal([]byte(mapping), &entries); err != nil {
+		return nil, err
+	}
+
+	rv := make([]ingestionMappingEntry, 0, len(entries))
+	for _, e := range entries {
+		entry := e.ingestionMappingEntry
+		if entry.Properties.Path == "" {
+			entry.Properties.Path = e.Path
+		}
+		if entry.Properties.Ordinal == "" {
+			entry.Properties.Ordinal = e.Ordinal
+		}
+		if entry.Properties.Transform == "" {
+			entry.Properties.Transform = e.Transform
+		}
+		rv = append(rv, entry)
+	}
+
+	return rv, nil
+}
+
+// mappingsEqual compares the mappings, data types are only compared when desired.
+func mappingsEqual(desired []ingestionMappingEntry, current []ingestionMappingEntry) bool {
+	if len(desired) != len(current) {
+		return false
+	}
+	for i := range desired {
+		d, c := desired[i], current[i]
+		if d.DataType == "" {
+			c.DataType = ""
+		}
+		if d != c {
+			return false
+		}
+	}
+
+	return true
+}
+
+// diffTable plans the changes of the table. Columns and mappings that are not in
+// the desired state are reported, but not dropped.
+func diffTable(desired desiredTable, current *currentTable) (statePlan, error) {
+	var plan statePlan
+	table := quoteIdentifier(desired.Name)
+
+	var columns []tableColumn
+	for _, c := range desired.Columns {
+		columns = append(columns, tableColumn{Name: c.Name, Type: c.Type})
+	}
+
+	if current == nil {
+		changes := []stateChange{{Action: stateChangeCreate, Subject: "table " + desired.Name}}
+		for _, c := range desired.Columns {
+			changes = append(changes, stateChange{Action: stateChangeCreate, Subject: "column " + desired.Name + "." + c.Name, Detail: c.Type})
+		}
+		plan.add(buildTableCommand(".create-merge table", desired.Name, columns), changes...)
+		current = &currentTable{}
+	} else {
+		currentTypes := map[string]string{}
+		for _, c := range current.Columns {
+			currentTypes[c.Name] = c.Type
+		}
+
+		var added []stateChange
+		for _, c := range desired.Columns {
+			currentType, ok := currentTypes[c.Name]
+			switch {
+			case !ok:
+				added = append(added, stateChange{Action: stateChangeCreate, Subject: "column " + desired.Name + "." + c.Name, Detail: c.Type})
+			case canonicalKustoType(currentType) != canonicalKustoType(c.Type):
+				// the existing data is converted or lost, applied with --allow-destructive only
+				plan.add(
+					".alter column "+table+"."+quoteIdentifier(c.Name)+" type="+c.Type,
+					stateChange{Action: stateChangeUpdate, Subject: "column " + desired.Name + "." + c.Name, Detail: currentType + " -> " + c.Type + " (destructive)"},
+				)
+			}
+			delete(currentTypes, c.Name)
+		}
+		if len(added) > 0 {
+			plan.add(buildTableCommand(".create-merge table", desired.Name, columns), added...)
+		}
+		for _, c := range current.Columns {
+			if _, ok := currentTypes[c.Name]; ok {
+				plan.add("", stateChange{Action: stateChangeIgnore, Subject: "column " + desired.Name + "." + c.Name, Detail: "not in the desired state, not dropped"})
+			}
+		}
+	}
+
+	desiredMappings := map[string]bool{}
+	for _, m := range desired.Mappings {
+		kind := strings.ToLower(m.Kind)
+		desiredMappings[m.Name+"/"+kind] = true
+
+		mapping, err := json.Marshal(m.Mapping)
+		if err != nil {
+			return plan, fmt.Errorf("mapping %q: %w", m.Name, err)
+		}
+		command := ".create-or-alter table " + table + " ingestion " + kind + " mapping " +
+			kqlVerbatimString(m.Name) + " " + kqlVerbatimString(string(mapping))
+		subject := "mapping " + desired.Name + "." + m.Name + " (" + kind + ")"
+
+		idx := -1
+		for i, c := range current.Mappings {
+			if c.Name == m.Name && strings.EqualFold(c.Kind, kind) {
+				idx = i
+			}
+		}
+		if idx < 0 {
+			plan.add(command, stateChange{Action: stateChangeCreate, Subject: subject})
+			continue
+		}
+
+		currentMapping, err := normalizeMapping(current.Mappings[idx].Mapping)
+		if err != nil || !mappingsEqual(m.Mapping, currentMapping) {
+			plan.add(command, stateChange{Action: stateChangeUpdate, Subject: subject})
+		}
+	}
+	for _, c := range current.Mappings {
+		if !desiredMappings[c.Name+"/"+strings.ToLower(c.Kind)] {
+			plan.add("", stateChange{
+				Action:  stateChangeIgnore,
+				Subject: "mapping " + desired.Name + "." + c.Name + " (" + strings.ToLower(c.Kind) + ")",
+				Detail:  "not in the desired state, not dropped",
+			})
+		}
+	}
+
+	if p := desired.Policies.Retention; p != nil {
+		desiredPeriod, _ := parseKustoTimespan(p.SoftDeletePeriod)
+
+		var details []string
+		command := ".alter-merge table " + table + " policy retention softdelete = " + formatKustoTimespan(desiredPeriod)
+		if current.Retention == nil {
+			details = append(details, "softDeletePeriod "+formatKustoTimespan(desiredPeriod))
+		} else if currentPeriod, err := parseKustoTimespan(current.Retention.SoftDeletePeriod); err != nil || currentPeriod != desiredPeriod {
+			details = append(details, "softDeletePeriod "+current.Retention.SoftDeletePeriod+" -> "+formatKustoTimespan(desiredPeriod))
+		}
+		if p.Recoverability != "" {
+			recoverability := strings.ToLower(p.Recoverability)
+			command += " recoverability = " + recoverability
+			if current.Retention == nil || !strings.EqualFold(current.Retention.Recoverability, recoverability) {
+				details = append(details, "recoverability "+recoverability)
+			}
+		}
+		if len(details) > 0 {
+			plan.add(command, stateChange{Action: stateChangeUpdate, Subject: "retention policy " + desired.Name, Detail: strings.Join(details, ", ")})
+		}
+	}
+
+	if p := desired.Policies.Caching; p != nil {
+		desiredHot, _ := parseKustoTimespan(p.Hot)
+
+		change := stateChange{Action: stateChangeUpdate, Subject: "caching policy " + desired.Name, Detail: "hot " + formatKustoTimespan(desiredHot)}
+		update := current.Caching == nil
+		if current.Caching != nil {
+			currentHot, err := parseKustoTimespan(string(current.Caching.DataHotSpan))
+			if err != nil || currentHot != desiredHot {
+				update = true
+				change.Detail = "hot " + string(current.Caching.DataHotSpan) + " -> " + formatKustoTimespan(desiredHot)
+			}
+		}
+		if update {
+			plan.add(".alter table "+table+" policy caching hot = "+formatKustoTimespan(desiredHot), change)
+		}
+	}
+
+	if desired.Policies.Update != nil && !reflect.DeepEqual(desired.Policies.Update, current.Update) {
+		if len(desired.Policies.Update) > 0 || len(current.Update) > 0 {
+			policy, err := json.Marshal(desired.Policies.Update)
+			if err != nil {
+				return plan, fmt.Errorf("update policy: %w", err)
+			}
+			plan.add(
+				".alter table "+table+" policy update "+kqlVerbatimString(string(policy)),
+				stateChange{Action: stateChangeUpdate, Subject: "update policy " + desired.Name, Detail: fmt.Sprintf("%d policies", len(desired.Policies.Update))},
+			)
+		}
+	}
+
+	return plan, nil
+}
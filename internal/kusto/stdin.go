@@ -0,0 +1,32 @@
+package kusto
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// stdinSourceFile is the source file name that reads the data from stdin.
+const stdinSourceFile = "-"
+
+// spoolToTempFile copies the reader to a temp file so it can be read again on retries.
+// The caller is responsible for removing the returned file.
+func spoolToTempFile(r io.Reader) (string, error) {
+	f, err := os.CreateTemp("", "kusto-ingest-stdin-*")
+	if err != nil {
+		return "", fmt.Errorf("create temp file: %w", err)
+	}
+
+	if _, err := io.Copy(f, r); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return "", fmt.Errorf("copy to temp file: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return "", fmt.Errorf("close temp file: %w", err)
+	}
+
+	return f.Name(), nil
+}
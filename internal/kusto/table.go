@@ -0,0 +1,182 @@
+package kusto
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/ingest"
+	"github.com/Azure/azure-kusto-go/kusto/kql"
+	"github.com/Azure/kusto-ingest/internal/cli"
+)
+
+// tableRecord is a row of the `.show tables` result.
+type tableRecord struct {
+	TableName string `kusto:"TableName"`
+}
+
+// tableExists checks whether the target table exists in the target database.
+func tableExists(
+	ctx context.Context,
+	queryClient ingest.QueryClient,
+	target KustoTargetOptions,
+) (bool, error) {
+	tables, err := showTableNames(ctx, queryClient, target.Database)
+	if err != nil {
+		return false, err
+	}
+
+	return tables[target.Table], nil
+}
+
+// showTableNames lists the tables of the database.
+func showTableNames(ctx context.Context, queryClient ingest.QueryClient, database string) (map[string]bool, error) {
+	iter, err := queryClient.Mgmt(ctx, database, kql.New(".show tables"))
+	if err != nil {
+		return nil, err
+	}
+	rv := map[string]bool{}
+	if iter == nil {
+		return rv, nil
+	}
+	defer iter.Stop()
+
+	err = iter.DoOnRowOrError(func(row *table.Row, inlineErr *errors.Error) error {
+		if inlineErr != nil {
+			return inlineErr
+		}
+
+		var rec tableRecord
+		if err := row.ToStruct(&rec); err != nil {
+			return err
+		}
+		rv[rec.TableName] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return rv, nil
+}
+
+// tableColumnsFromMappingFile reads the table columns from the Column and DataType
+// of the ingestion mapping file. Columns without DataType default to string.
+func tableColumnsFromMappingFile(mappingsFile string) ([]tableColumn, error) {
+	content, err := os.ReadFile(mappingsFile)
+	if err != nil {
+		return nil, fmt.Errorf("read mappings file %q: %w", mappingsFile, err)
+	}
+
+	var mapping []ingestionMappingEntry
+	if err := json.Unmarshal(content, &mapping); err != nil {
+		return nil, fmt.Errorf("parse mappings file %q: %w", mappingsFile, err)
+	}
+
+	var rv []tableColumn
+	for i, m := range mapping {
+		if m.Column == "" {
+			return nil, fmt.Errorf("mappings file %q: entry %d has no Column", mappingsFile, i)
+		}
+
+		dataType := m.DataType
+		if dataType == "" {
+			dataType = kustoTypeString
+		}
+		rv = append(rv, tableColumn{Name: m.Column, Type: dataType})
+	}
+	if len(rv) == 0 {
+		return nil, fmt.Errorf("mappings file %q has no columns", mappingsFile)
+	}
+
+	return rv, nil
+}
+
+// tableColumnsForCreate derives the columns of the target table from the mappings
+// file, or from sampling the first source file.
+func (f FileIngestOptions) tableColumnsForCreate(cli cli.Provider, sourceFiles []string) ([]tableColumn, error) {
+	if f.MappingsFile != "" {
+		return tableColumnsFromMappingFile(f.MappingsFile)
+	}
+
+	if len(sourceFiles) == 0 {
+		return nil, fmt.Errorf("no source files to derive the table schema from")
+	}
+	if sourceFiles[0] == stdinSourceFile {
+		// stdin can only be read once, it's needed for the ingestion
+		return nil, fmt.Errorf("deriving the table schema from stdin is not supported, use --mappings-file")
+	}
+
+	header := csvHeaderAbsent
+	if f.Properties.IgnoreFirstRecord {
+		header = csvHeaderPresent
+	}
+	schema, _, err := inferSchemaFromFile(
+		cli,
+		sourceFiles[0],
+		f.Format,
+		f.Compression,
+		inferSchemaOptions{
+			SampleSize: 100,
+			Nested:     nestedDynamic,
+			Header:     header,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("derive table schema: %w", err)
+	}
+	reportWidenedColumns(cli, schema)
+
+	return schema.tableColumns(), nil
+}
+
+// createTableIfMissing creates the target table when it doesn't exist, so that
+// the ingestion doesn't fail after the data is queued.
+func (f FileIngestOptions) createTableIfMissing(ctx context.Context, cli cli.Provider, sourceFiles []string) error {
+	queryClient, err := f.createQueryClient(f.KustoTarget, f.Auth)
+	if err != nil {
+		return fmt.Errorf("create Kusto query client: %w", err)
+	}
+	defer func() { _ = queryClient.Close() }()
+
+	var exists bool
+	invokeShow := func() error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		var err error
+		exists, err = tableExists(ctx, queryClient, f.KustoTarget)
+		return err
+	}
+	if err := invokeWithRetries(invokeShow, f.MaxRetries, f.MaxTimeout, cli.Logger()); err != nil {
+		return fmt.Errorf("check table %q: %w", f.KustoTarget.Table, err)
+	}
+	if exists {
+		cli.Logger().Debug("table exists", "table", f.KustoTarget.Table)
+		return nil
+	}
+
+	columns, err := f.tableColumnsForCreate(cli, sourceFiles)
+	if err != nil {
+		return fmt.Errorf("create table %q: %w", f.KustoTarget.Table, err)
+	}
+
+	command := buildTableCommand(".create table", f.KustoTarget.Table, columns)
+	cli.Logger().Info("creating missing table", "table", f.KustoTarget.Table, "columns", len(columns))
+	cli.Logger().Debug("create table command", "command", command)
+
+	start := time.Now()
+	stmt := kql.New("").AddUnsafe(command)
+	err = executeMgmt(ctx, cli, queryClient, f.KustoTarget.Database, stmt, f.MaxRetries, f.MaxTimeout)
+	if err != nil {
+		return fmt.Errorf("create table %q: %w", f.KustoTarget.Table, err)
+	}
+
+	cli.Logger().Info("table created", "table", f.KustoTarget.Table, "duration", time.Since(start))
+	return nil
+}
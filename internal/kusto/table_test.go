@@ -0,0 +1,133 @@
+package kusto
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto"
+	"github.com/Azure/azure-kusto-go/kusto/ingest"
+	"github.com/Azure/kusto-ingest/internal/cli/testingcli"
+	"github.com/Azure/kusto-ingest/internal/kusto/testingkusto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_tableColumnsFromMappingFile(t *testing.T) {
+	columns, err := tableColumnsFromMappingFile("../../testdata/logs.mapping.json")
+	require.NoError(t, err)
+	assert.Equal(t, []tableColumn{
+		{Name: "PreciseTimestamp", Type: kustoTypeDatetime},
+		{Name: "msg", Type: kustoTypeString},
+	}, columns)
+
+	noDataType := writeToTestFile(t, "mapping.json", []byte(`[{"Column": "a", "Properties": {"Ordinal": "0"}}]`))
+	columns, err = tableColumnsFromMappingFile(noDataType)
+	require.NoError(t, err)
+	assert.Equal(t, []tableColumn{{Name: "a", Type: kustoTypeString}}, columns)
+
+	empty := writeToTestFile(t, "mapping.json", []byte(`[]`))
+	_, err = tableColumnsFromMappingFile(empty)
+	assert.Error(t, err)
+}
+
+func Test_FileIngestOptions_Run_CreateTableIfMissing(t *testing.T) {
+	sourceFile := writeToTestFile(t, "logs.csv", []byte("2024-01-01T00:00:00Z,hello,1\n"))
+
+	newOpts := func(tables []string, mappingsFile string, showErr error) (FileIngestOptions, *[]string, *bool) {
+		var commands []string
+		ingested := false
+
+		q := testingkusto.NewQueryClient(func(qc *testingkusto.QueryClient) {
+			qc.MgmtFn = func(_ context.Context, db string, stmt kusto.Statement, _ ...kusto.QueryOption) (*kusto.RowIterator, error) {
+				assert.Equal(t, "TestDatabase", db)
+				if stmt.String() == ".show tables" {
+					if showErr != nil {
+						return nil, showErr
+					}
+					var rows [][]string
+					for _, name := range tables {
+						rows = append(rows, []string{name, "TestDatabase"})
+					}
+					return newTestStringRows(t, []string{"TableName", "DatabaseName"}, rows...), nil
+				}
+
+				commands = append(commands, stmt.String())
+				return nil, nil
+			}
+		})
+		ingestor := testingkusto.New(func(ing *testingkusto.Ingestor) {
+			ing.FromFileFunc = func(ctx context.Context, fPath string, options ...ingest.FileOption) (*ingest.Result, error) {
+				ingested = true
+				return &ingest.Result{}, nil
+			}
+		})
+
+		return FileIngestOptions{
+			SourceFiles:          []string{sourceFile},
+			Format:               "csv",
+			MappingsFile:         mappingsFile,
+			CreateTableIfMissing: true,
+			Auth:                 newTestAuth(),
+			KustoTarget:          newTestKustoTarget(),
+
+			ingestorBuildSettings: ingestorBuildSettings{
+				CreateQueryClient: func(target KustoTargetOptions, auth AuthOptions) (ingest.QueryClient, error) {
+					return q, nil
+				},
+				CreateIngestor: func(target KustoTargetOptions, auth AuthOptions) (ingest.Ingestor, error) {
+					return ingestor, nil
+				},
+			},
+		}, &commands, &ingested
+	}
+
+	t.Run("table exists", func(t *testing.T) {
+		opts, commands, ingested := newOpts([]string{"OtherTable", "TestTable"}, "", nil)
+		require.NoError(t, opts.Run(testingcli.New()))
+		assert.Empty(t, *commands)
+		assert.True(t, *ingested)
+	})
+
+	t.Run("create from sampled data", func(t *testing.T) {
+		opts, commands, ingested := newOpts([]string{"OtherTable"}, "", nil)
+		require.NoError(t, opts.Run(testingcli.New()))
+		assert.Equal(t, []string{
+			".create table TestTable (\n    Column1: datetime,\n    Column2: string,\n    Column3: long\n)\n",
+		}, *commands)
+		assert.True(t, *ingested)
+	})
+
+	t.Run("create from mappings file", func(t *testing.T) {
+		mappingsFile := writeToTestFile(t, "mapping.json", []byte(`[
+			{"Column": "ts", "DataType": "datetime", "Properties": {"Ordinal": "0"}},
+			{"Column": "msg", "DataType": "string", "Properties": {"Ordinal": "1"}}
+		]`))
+		opts, commands, ingested := newOpts(nil, mappingsFile, nil)
+		require.NoError(t, opts.Run(testingcli.New()))
+		if assert.Len(t, *commands, 1) {
+			assert.True(t, strings.HasPrefix((*commands)[0], ".create table TestTable (\n    ts: datetime,\n    msg: string\n)"))
+		}
+		assert.True(t, *ingested)
+	})
+
+	t.Run("check table error", func(t *testing.T) {
+		opts, commands, ingested := newOpts(nil, "", errors.New("boom"))
+		opts.MaxRetries = 0
+		err := opts.Run(testingcli.New())
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), `check table "TestTable"`)
+		assert.Empty(t, *commands)
+		assert.False(t, *ingested, "should fail before ingestion")
+	})
+
+	t.Run("stdin without mappings file", func(t *testing.T) {
+		opts, _, ingested := newOpts(nil, "", nil)
+		opts.SourceFiles = []string{stdinSourceFile}
+		err := opts.Run(testingcli.New())
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "use --mappings-file")
+		assert.False(t, *ingested)
+	})
+}
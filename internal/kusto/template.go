@@ -0,0 +1,91 @@
+package kusto
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// parseTemplateVar parses a KEY=VALUE template variable.
+func parseTemplateVar(v string) (string, string, error) {
+	key, value, ok := strings.Cut(v, "=")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" {
+		return "", "", fmt.Errorf("invalid variable %q, expected KEY=VALUE", v)
+	}
+
+	return key, value, nil
+}
+
+// loadTemplateVars reads the variables of the vars file, then of the flags,
+// which override the file. The vars file has a KEY=VALUE per line, blank lines
+// and lines starting with # are skipped.
+func loadTemplateVars(varsFile string, vars []string) (map[string]string, error) {
+	rv := map[string]string{}
+
+	if varsFile != "" {
+		content, err := os.ReadFile(varsFile)
+		if err != nil {
+			return nil, fmt.Errorf("read vars file %q: %w", varsFile, err)
+		}
+
+		scanner := bufio.NewScanner(bytes.NewReader(content))
+		for n := 1; scanner.Scan(); n++ {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+
+			key, value, err := parseTemplateVar(line)
+			if err != nil {
+				return nil, fmt.Errorf("vars file %q line %d: %w", varsFile, n, err)
+			}
+			rv[key] = value
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("read vars file %q: %w", varsFile, err)
+		}
+	}
+
+	for _, v := range vars {
+		key, value, err := parseTemplateVar(v)
+		if err != nil {
+			return nil, fmt.Errorf("--var: %w", err)
+		}
+		rv[key] = value
+	}
+
+	return rv, nil
+}
+
+// templateEnv returns the value of the environment variable, failing when it's not set.
+func templateEnv(name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %q is not set", name)
+	}
+
+	return value, nil
+}
+
+// renderTemplate renders the source as text/template with the variables as data.
+// Environment variables are read with {{ env "NAME" }}. Undefined variables are errors.
+func renderTemplate(name string, source string, vars map[string]string) (string, error) {
+	tmpl, err := template.New(name).
+		Option("missingkey=error").
+		Funcs(template.FuncMap{"env": templateEnv}).
+		Parse(source)
+	if err != nil {
+		return "", fmt.Errorf("parse template: %w", err)
+	}
+
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return "", fmt.Errorf("render template: %w", err)
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,55 @@
+package kusto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_loadTemplateVars(t *testing.T) {
+	varsFile := writeToTestFile(t, "prod.vars", []byte(`# production
+database=LogsProd
+retention = P365D
+
+principal=aadapp=00000000-0000-0000-0000-000000000000;contoso.com
+`))
+
+	vars, err := loadTemplateVars(varsFile, []string{"retention=P30D", "empty="})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"database":  "LogsProd",
+		"retention": "P30D",
+		"principal": "aadapp=00000000-0000-0000-0000-000000000000;contoso.com",
+		"empty":     "",
+	}, vars)
+
+	_, err = loadTemplateVars("", []string{"database"})
+	assert.ErrorContains(t, err, `--var: invalid variable "database", expected KEY=VALUE`)
+
+	invalidFile := writeToTestFile(t, "invalid.vars", []byte("a=1\n=2\n"))
+	_, err = loadTemplateVars(invalidFile, nil)
+	assert.ErrorContains(t, err, "line 2: invalid variable")
+}
+
+func Test_renderTemplate(t *testing.T) {
+	t.Setenv("KUSTO_INGEST_TEST_PRINCIPAL", "aadapp=test")
+
+	source := `.alter-merge database {{ .database }} policy retention softdelete = {{ .retention }}
+.add database {{ .database }} viewers ('{{ env "KUSTO_INGEST_TEST_PRINCIPAL" }}')
+`
+	rendered, err := renderTemplate("source", source, map[string]string{"database": "Logs", "retention": "30d"})
+	require.NoError(t, err)
+	assert.Equal(t, `.alter-merge database Logs policy retention softdelete = 30d
+.add database Logs viewers ('aadapp=test')
+`, rendered)
+
+	_, err = renderTemplate("source", source, map[string]string{"database": "Logs"})
+	assert.ErrorContains(t, err, `map has no entry for key "retention"`)
+
+	_, err = renderTemplate("source", `{{ env "KUSTO_INGEST_TEST_UNSET" }}`, nil)
+	assert.ErrorContains(t, err, `environment variable "KUSTO_INGEST_TEST_UNSET" is not set`)
+
+	_, err = renderTemplate("source", `{{ .database`, nil)
+	assert.ErrorContains(t, err, "parse template")
+}
@@ -0,0 +1,218 @@
+package kusto
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// recordTransform rewrites the top-level fields of JSON records before ingestion.
+// The fields are dropped first, then renamed, then the constant columns are added,
+// overriding existing fields with the same name.
+type recordTransform struct {
+	Drop   []string
+	Rename []fieldRename
+	Add    []constantColumn
+}
+
+type fieldRename struct {
+	From string
+	To   string
+}
+
+type constantColumn struct {
+	Name  string
+	Value string
+}
+
+// transformSpec is the format of the --transform-spec file, e.g.
+//
+//	{
+//	  "addColumns": {"environment": "prod"},
+//	  "rename": {"ts": "Timestamp"},
+//	  "drop": ["debug"]
+//	}
+type transformSpec struct {
+	AddColumns map[string]string `json:"addColumns"`
+	Rename     map[string]string `json:"rename"`
+	Drop       []string          `json:"drop"`
+}
+
+// IsEmpty reports whether the transform doesn't change the records.
+func (t recordTransform) IsEmpty() bool {
+	return len(t.Drop) == 0 && len(t.Rename) == 0 && len(t.Add) == 0
+}
+
+// recordTransform builds the transform from the spec file and the flags.
+func (o TransformOptions) recordTransform() (recordTransform, error) {
+	var rv recordTransform
+
+	if o.TransformSpec != "" {
+		content, err := os.ReadFile(o.TransformSpec)
+		if err != nil {
+			return rv, fmt.Errorf("read transform spec %q: %w", o.TransformSpec, err)
+		}
+
+		var spec transformSpec
+		dec := json.NewDecoder(bytes.NewReader(content))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&spec); err != nil {
+			return rv, fmt.Errorf("parse transform spec %q: %w", o.TransformSpec, err)
+		}
+
+		rv.Drop = append(rv.Drop, spec.Drop...)
+		// map order is random, sort for stable output
+		for _, from := range sortedKeys(spec.Rename) {
+			rv.Rename = append(rv.Rename, fieldRename{From: from, To: spec.Rename[from]})
+		}
+		for _, name := range sortedKeys(spec.AddColumns) {
+			rv.Add = append(rv.Add, constantColumn{Name: name, Value: spec.AddColumns[name]})
+		}
+	}
+
+	rv.Drop = append(rv.Drop, o.Drop...)
+	for _, v := range o.Rename {
+		from, to, ok := strings.Cut(v, "=")
+		if !ok || from == "" || to == "" {
+			return rv, fmt.Errorf("invalid --rename %q, expected OLD=NEW", v)
+		}
+		rv.Rename = append(rv.Rename, fieldRename{From: from, To: to})
+	}
+	for _, v := range o.AddColumn {
+		name, value, ok := strings.Cut(v, "=")
+		if !ok || name == "" {
+			return rv, fmt.Errorf("invalid --add-column %q, expected NAME=VALUE", v)
+		}
+		rv.Add = append(rv.Add, constantColumn{Name: name, Value: value})
+	}
+
+	return rv, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	return keys
+}
+
+// apply rewrites a single JSON object record, keeping the order of the fields.
+// The record is written as a single line.
+func (t recordTransform) apply(record []byte) ([]byte, error) {
+	fields, err := decodeJSONObject(record)
+	if err != nil {
+		return nil, err
+	}
+
+	var rv []jsonField
+	for _, field := range fields {
+		if slices.Contains(t.Drop, field.Key) {
+			continue
+		}
+		from := field.Key
+		for _, r := range t.Rename {
+			if field.Key == r.From {
+				field.Key = r.To
+				break
+			}
+		}
+		// a rename onto a kept field would write the key twice
+		if slices.ContainsFunc(rv, func(f jsonField) bool { return f.Key == field.Key }) {
+			return nil, fmt.Errorf("rename %q to %q: the record already has the field %q, drop it first", from, field.Key, field.Key)
+		}
+		rv = append(rv, field)
+	}
+
+	for _, c := range t.Add {
+		value, err := json.Marshal(c.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		i := slices.IndexFunc(rv, func(f jsonField) bool { return f.Key == c.Name })
+		if i >= 0 {
+			rv[i].Value = value
+			continue
+		}
+		rv = append(rv, jsonField{Key: c.Name, Value: value})
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, field := range rv {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(field.Key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		if err := json.Compact(&buf, field.Value); err != nil {
+			return nil, err
+		}
+	}
+	buf.WriteString("}\n")
+
+	return buf.Bytes(), nil
+}
+
+// transformRecords streams the JSON records of r through the transform into w,
+// one record per line.
+func (t recordTransform) transformRecords(r io.Reader, w io.Writer) error {
+	rr, err := newJSONRecordReader(r)
+	if err != nil {
+		return fmt.Errorf("read record: %w", err)
+	}
+
+	for n := 1; ; n++ {
+		record, err := rr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read record %d: %w", n, err)
+		}
+
+		transformed, err := t.apply(record)
+		if err != nil {
+			return fmt.Errorf("transform record %d: %w", n, err)
+		}
+		if _, err := w.Write(transformed); err != nil {
+			return err
+		}
+	}
+}
+
+// openTransformed opens the file at path, transforms its records while streaming
+// and returns them compressed with gzip.
+func openTransformed(path string, compression CompressionString, t recordTransform) (io.ReadCloser, error) {
+	r, err := openDecompressed(path, compression)
+	if err != nil {
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		defer func() { _ = r.Close() }()
+
+		gw := gzip.NewWriter(pw)
+		err := t.transformRecords(r, gw)
+		if err == nil {
+			err = gw.Close()
+		}
+		_ = pw.CloseWithError(err)
+	}()
+
+	return pr, nil
+}
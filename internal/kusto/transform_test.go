@@ -0,0 +1,232 @@
+package kusto
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto/ingest"
+	"github.com/Azure/kusto-ingest/internal/cli/testingcli"
+	"github.com/Azure/kusto-ingest/internal/kusto/testingkusto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_recordTransform_apply(t *testing.T) {
+	cases := []struct {
+		name      string
+		transform recordTransform
+		record    string
+		expected  string
+	}{
+		{
+			name:      "empty",
+			transform: recordTransform{},
+			record:    `{"b": 1, "a": {"x": [1, 2]}}`,
+			expected:  `{"b":1,"a":{"x":[1,2]}}` + "\n",
+		},
+		{
+			name:      "drop",
+			transform: recordTransform{Drop: []string{"debug", "missing"}},
+			record:    `{"msg": "hello", "debug": true}`,
+			expected:  `{"msg":"hello"}` + "\n",
+		},
+		{
+			name:      "rename keeps order",
+			transform: recordTransform{Rename: []fieldRename{{From: "ts", To: "Timestamp"}}},
+			record:    `{"ts": "2024-01-01", "msg": "hello"}`,
+			expected:  `{"Timestamp":"2024-01-01","msg":"hello"}` + "\n",
+		},
+		{
+			name: "add overrides existing field",
+			transform: recordTransform{Add: []constantColumn{
+				{Name: "env", Value: "prod"},
+				{Name: "region", Value: `west"us`},
+			}},
+			record:   `{"env": "dev", "msg": "hello"}`,
+			expected: `{"env":"prod","msg":"hello","region":"west\"us"}` + "\n",
+		},
+		{
+			name: "drop before rename",
+			transform: recordTransform{
+				Drop:   []string{"level"},
+				Rename: []fieldRename{{From: "severity", To: "level"}},
+			},
+			record:   `{"level": "info", "severity": 3}`,
+			expected: `{"level":3}` + "\n",
+		},
+		{
+			name: "swap renames",
+			transform: recordTransform{Rename: []fieldRename{
+				{From: "a", To: "b"},
+				{From: "b", To: "a"},
+			}},
+			record:   `{"a": 1, "b": 2}`,
+			expected: `{"b":1,"a":2}` + "\n",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			actual, err := c.transform.apply([]byte(c.record))
+			require.NoError(t, err)
+			assert.Equal(t, c.expected, string(actual))
+		})
+	}
+
+	t.Run("rename onto existing field", func(t *testing.T) {
+		transform := recordTransform{Rename: []fieldRename{{From: "severity", To: "level"}}}
+		_, err := transform.apply([]byte(`{"level": "info", "severity": 3}`))
+		assert.ErrorContains(t, err, `rename "severity" to "level": the record already has the field "level"`)
+	})
+
+	t.Run("not an object", func(t *testing.T) {
+		_, err := recordTransform{}.apply([]byte(`[1, 2]`))
+		assert.Error(t, err)
+	})
+}
+
+func Test_TransformOptions_recordTransform(t *testing.T) {
+	t.Run("flags", func(t *testing.T) {
+		transform, err := TransformOptions{
+			AddColumn: []string{"env=prod", "note=a=b", "empty="},
+			Rename:    []string{"ts=Timestamp"},
+			Drop:      []string{"debug"},
+		}.recordTransform()
+		require.NoError(t, err)
+		assert.Equal(t, recordTransform{
+			Drop:   []string{"debug"},
+			Rename: []fieldRename{{From: "ts", To: "Timestamp"}},
+			Add: []constantColumn{
+				{Name: "env", Value: "prod"},
+				{Name: "note", Value: "a=b"},
+				{Name: "empty", Value: ""},
+			},
+		}, transform)
+	})
+
+	t.Run("spec file and flags", func(t *testing.T) {
+		spec := writeToTestFile(t, "transform.json", []byte(`{
+			"addColumns": {"region": "westus", "env": "dev"},
+			"rename": {"ts": "Timestamp"},
+			"drop": ["debug"]
+		}`))
+		transform, err := TransformOptions{
+			AddColumn:     []string{"env=prod"},
+			Drop:          []string{"trace"},
+			TransformSpec: spec,
+		}.recordTransform()
+		require.NoError(t, err)
+		assert.Equal(t, recordTransform{
+			Drop:   []string{"debug", "trace"},
+			Rename: []fieldRename{{From: "ts", To: "Timestamp"}},
+			Add: []constantColumn{
+				{Name: "env", Value: "dev"},
+				{Name: "region", Value: "westus"},
+				{Name: "env", Value: "prod"},
+			},
+		}, transform)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := TransformOptions{Rename: []string{"ts"}}.recordTransform()
+		assert.ErrorContains(t, err, `invalid --rename "ts", expected OLD=NEW`)
+
+		_, err = TransformOptions{AddColumn: []string{"=prod"}}.recordTransform()
+		assert.ErrorContains(t, err, `invalid --add-column "=prod", expected NAME=VALUE`)
+
+		spec := writeToTestFile(t, "transform.json", []byte(`{"add": {"env": "prod"}}`))
+		_, err = TransformOptions{TransformSpec: spec}.recordTransform()
+		assert.ErrorContains(t, err, "parse transform spec")
+	})
+}
+
+func Test_FileIngestOptions_Validate_Transform(t *testing.T) {
+	opts := FileIngestOptions{
+		Format:      "csv",
+		Transform:   TransformOptions{Drop: []string{"debug"}},
+		KustoTarget: newTestKustoTarget(),
+	}
+	assert.ErrorContains(t, opts.Validate(), "transformations are only supported for json and multijson sources")
+
+	opts.Format = "multijson"
+	assert.NoError(t, opts.Validate())
+
+	opts.Transform.Rename = []string{"invalid"}
+	assert.ErrorContains(t, opts.Validate(), "invalid --rename")
+}
+
+func Test_FileIngestOptions_Run_Transform(t *testing.T) {
+	content := []byte("{\"ts\": 1, \"debug\": true}\n{\"ts\": 2}\n")
+
+	run := func(t *testing.T, sourceFile string, compression CompressionString, maxChunkBytes int64) []string {
+		var uploaded []string
+		ingestor := testingkusto.New(func(ing *testingkusto.Ingestor) {
+			ing.FromReaderFunc = func(ctx context.Context, reader io.Reader, options ...ingest.FileOption) (*ingest.Result, error) {
+				gr, err := gzip.NewReader(reader)
+				require.NoError(t, err)
+				b, err := io.ReadAll(gr)
+				require.NoError(t, err)
+				uploaded = append(uploaded, string(b))
+				return &ingest.Result{}, nil
+			}
+		})
+
+		opts := FileIngestOptions{
+			SourceFiles:   []string{sourceFile},
+			Format:        "json",
+			Compression:   compression,
+			MaxChunkBytes: maxChunkBytes,
+			Transform: TransformOptions{
+				AddColumn: []string{"env=prod"},
+				Rename:    []string{"ts=Timestamp"},
+				Drop:      []string{"debug"},
+			},
+			Auth:        newTestAuth(),
+			KustoTarget: newTestKustoTarget(),
+
+			ingestorBuildSettings: ingestorBuildSettings{
+				CreateIngestor: func(target KustoTargetOptions, auth AuthOptions) (ingest.Ingestor, error) {
+					return ingestor, nil
+				},
+			},
+		}
+		require.NoError(t, opts.Run(testingcli.New()))
+
+		return uploaded
+	}
+
+	t.Run("whole file", func(t *testing.T) {
+		uploaded := run(t, writeToTestFile(t, "logs.json", content), CompressionAuto, 0)
+		assert.Equal(t, []string{
+			"{\"Timestamp\":1,\"env\":\"prod\"}\n{\"Timestamp\":2,\"env\":\"prod\"}\n",
+		}, uploaded)
+	})
+
+	t.Run("compressed file", func(t *testing.T) {
+		uploaded := run(t, writeToTestFile(t, "logs.json.gz", gzipBytes(t, content)), CompressionAuto, 0)
+		assert.Equal(t, []string{
+			"{\"Timestamp\":1,\"env\":\"prod\"}\n{\"Timestamp\":2,\"env\":\"prod\"}\n",
+		}, uploaded)
+	})
+
+	t.Run("per chunk", func(t *testing.T) {
+		uploaded := run(t, writeToTestFile(t, "logs.json", content), CompressionAuto, 20)
+		assert.ElementsMatch(t, []string{
+			"{\"Timestamp\":1,\"env\":\"prod\"}\n",
+			"{\"Timestamp\":2,\"env\":\"prod\"}\n",
+		}, uploaded)
+	})
+}
+
+func Test_openTransformed_invalidRecord(t *testing.T) {
+	sourceFile := writeToTestFile(t, "logs.json", []byte("{\"n\": 1}\n[1]\n"))
+
+	r, err := openTransformed(sourceFile, CompressionNone, recordTransform{Drop: []string{"n"}})
+	require.NoError(t, err)
+	defer func() { _ = r.Close() }()
+
+	_, err = io.ReadAll(r)
+	assert.ErrorContains(t, err, "transform record 2")
+}